@@ -3,7 +3,9 @@ package mem
 import (
 	"context"
 	"errors"
+	"path"
 	"sort"
+	"strings"
 	"sync"
 
 	datastore "github.com/ipfs/go-datastore"
@@ -39,6 +41,9 @@ func (ds *Datastore) Put(ctx context.Context, key datastore.Key, value []byte) e
 }
 
 func (ds *Datastore) Sync(ctx context.Context, prefix datastore.Key) error {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
 	if ds.store == nil {
 		return ErrClosed
 	}
@@ -106,8 +111,19 @@ func (ds *Datastore) Query(ctx context.Context, q query.Query) (query.Results, e
 		return nil, ErrClosed
 	}
 
+	// Like query.NaiveQueryApply, the prefix matches whole path segments: a
+	// prefix of /foo finds /foo/bar but not /foobar.
+	prefix := path.Clean("/" + q.Prefix)
+	if prefix != "/" {
+		prefix += "/"
+	}
+
 	var entries []query.Entry
 	for k, v := range ds.store {
+		if !strings.HasPrefix(k.String(), prefix) {
+			continue
+		}
+
 		e := query.Entry{Key: k.String(), Size: len(v)}
 		if !q.KeysOnly {
 			e.Value = make([]byte, len(v))
@@ -156,10 +172,20 @@ func (ds *Datastore) Query(ctx context.Context, q query.Query) (query.Results, e
 }
 
 func (ds *Datastore) Close() error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
 	ds.store = nil
 	return nil
 }
 
+func (ds *Datastore) closed() bool {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return ds.store == nil
+}
+
 type Batch struct {
 	ds   *Datastore
 	ops  []operation
@@ -173,6 +199,9 @@ type operation struct {
 }
 
 func (ds *Datastore) Batch(ctx context.Context) (datastore.Batch, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
 	if ds.store == nil {
 		return nil, ErrClosed
 	}
@@ -187,7 +216,7 @@ func (b *Batch) Put(ctx context.Context, key datastore.Key, value []byte) error
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	if b.ds.store == nil {
+	if b.ds.closed() {
 		return ErrClosed
 	}
 
@@ -199,7 +228,7 @@ func (b *Batch) Delete(ctx context.Context, key datastore.Key) error {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	if b.ds.store == nil {
+	if b.ds.closed() {
 		return ErrClosed
 	}
 
@@ -144,7 +144,7 @@ func TestDatastore_Query(t *testing.T) {
 
 	// Populate datastore with test data
 	for i := 0; i < 5; i++ {
-		key := datastore.NewKey(fmt.Sprintf("key%d", i))
+		key := datastore.NewKey(fmt.Sprintf("key/%d", i))
 		value := []byte(fmt.Sprintf("value%d", i))
 		err := ds.Put(ctx, key, value)
 		if err != nil {
@@ -522,3 +522,69 @@ func TestGetSizeOnClosedDatastore(t *testing.T) {
 		t.Errorf("GetSize did not return -1 after datastore closure, got %d", size)
 	}
 }
+
+func TestDatastore_QueryPrefix(t *testing.T) {
+	ctx := context.Background()
+	ds := New()
+
+	for _, k := range []string{"/a/1", "/a/2", "/b/1"} {
+		if err := ds.Put(ctx, datastore.NewKey(k), []byte(k)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	results, err := ds.Query(ctx, query.Query{Prefix: "/a/"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	entries, err := results.Rest()
+	if err != nil {
+		t.Fatalf("Failed to collect query results: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+}
+
+func TestDatastore_QueryPrefixSegments(t *testing.T) {
+	ctx := context.Background()
+	ds := New()
+
+	for _, k := range []string{"/foo", "/foo/1", "/foo/2/3", "/foobar", "/foobar/1"} {
+		if err := ds.Put(ctx, datastore.NewKey(k), []byte(k)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	for _, prefix := range []string{"/foo", "/foo/", "foo"} {
+		results, err := ds.Query(ctx, query.Query{Prefix: prefix, Orders: []query.Order{query.OrderByKey{}}})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		entries, err := results.Rest()
+		if err != nil {
+			t.Fatalf("Failed to collect query results: %v", err)
+		}
+
+		if len(entries) != 2 || entries[0].Key != "/foo/1" || entries[1].Key != "/foo/2/3" {
+			t.Fatalf("Expected /foo/1 and /foo/2/3 for prefix %q, got %v", prefix, entries)
+		}
+	}
+
+	results, err := ds.Query(ctx, query.Query{Prefix: "/"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	entries, err := results.Rest()
+	if err != nil {
+		t.Fatalf("Failed to collect query results: %v", err)
+	}
+
+	if len(entries) != 5 {
+		t.Fatalf("Expected 5 entries for the root prefix, got %d", len(entries))
+	}
+}
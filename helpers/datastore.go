@@ -1,11 +1,164 @@
 package helpers
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/mount"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	pebbleds "github.com/ipfs/go-ds-pebble"
+
+	"github.com/taubyte/p2p/datastores/mem"
+)
+
+// Names of the built-in datastore backends.
+const (
+	DatastorePebble = "pebble"
+	DatastoreMem    = "mem"
+	// DatastoreFlatfs keeps blocks in a flatfs folder and everything else in pebble.
+	DatastoreFlatfs = "flatfs"
+)
+
+// DatastoreOptions tunes a datastore backend. Backends ignore the options they
+// do not support.
+type DatastoreOptions struct {
+	// CacheSize is the size in bytes of the backend's block cache. Zero keeps
+	// the backend default.
+	CacheSize int64
+	// SyncWrites makes writes durable before they return.
+	SyncWrites bool
+}
+
+// DatastoreFactory opens a datastore rooted at path.
+type DatastoreFactory func(path string, opts DatastoreOptions) (datastore.Batching, error)
 
-	pebble "github.com/ipfs/go-ds-pebble"
+var (
+	datastoresLock sync.RWMutex
+	datastores     = map[string]DatastoreFactory{
+		DatastorePebble: newPebbleDatastore,
+		DatastoreMem:    newMemDatastore,
+		DatastoreFlatfs: newFlatfsDatastore,
+	}
 )
 
+// RegisterDatastore makes a datastore backend available under name.
+func RegisterDatastore(name string, factory DatastoreFactory) error {
+	if factory == nil {
+		return fmt.Errorf("can not register nil datastore factory `%s`", name)
+	}
+
+	datastoresLock.Lock()
+	defer datastoresLock.Unlock()
+
+	if _, ok := datastores[name]; ok {
+		return fmt.Errorf("datastore `%s` already registered", name)
+	}
+
+	datastores[name] = factory
+	return nil
+}
+
+// GetDatastore returns the factory of the backend registered under name.
+func GetDatastore(name string) (DatastoreFactory, error) {
+	datastoresLock.RLock()
+	defer datastoresLock.RUnlock()
+
+	factory, ok := datastores[name]
+	if !ok {
+		return nil, fmt.Errorf("datastore `%s` is not registered", name)
+	}
+
+	return factory, nil
+}
+
+// NewDatastore opens the default pebble datastore at path.
 func NewDatastore(path string) (datastore.Batching, error) {
-	return pebble.NewDatastore(path, nil)
+	return newPebbleDatastore(path, DatastoreOptions{})
+}
+
+func newPebbleDatastore(path string, opts DatastoreOptions) (datastore.Batching, error) {
+	store, err := pebbleds.NewDatastore(path, pebbleds.WithCacheSize(opts.CacheSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.SyncWrites {
+		return &syncedDatastore{Batching: store}, nil
+	}
+
+	return store, nil
+}
+
+func newMemDatastore(string, DatastoreOptions) (datastore.Batching, error) {
+	return mem.New(), nil
+}
+
+func newFlatfsDatastore(path string, opts DatastoreOptions) (datastore.Batching, error) {
+	blocks, err := flatfs.CreateOrOpen(filepath.Join(path, "blocks"), flatfs.IPFS_DEF_SHARD, opts.SyncWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := newPebbleDatastore(path, opts)
+	if err != nil {
+		blocks.Close()
+		return nil, err
+	}
+
+	return mount.New([]mount.Mount{
+		{Prefix: blockstore.BlockPrefix, Datastore: blocks},
+		{Prefix: datastore.NewKey("/"), Datastore: store},
+	}), nil
+}
+
+// syncedDatastore syncs after every write for backends that do not offer
+// synchronous writes.
+type syncedDatastore struct {
+	datastore.Batching
+}
+
+func (s *syncedDatastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	if err := s.Batching.Put(ctx, key, value); err != nil {
+		return err
+	}
+
+	return s.Batching.Sync(ctx, key)
+}
+
+func (s *syncedDatastore) Delete(ctx context.Context, key datastore.Key) error {
+	if err := s.Batching.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	return s.Batching.Sync(ctx, key)
+}
+
+func (s *syncedDatastore) Batch(ctx context.Context) (datastore.Batch, error) {
+	b, err := s.Batching.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syncedBatch{Batch: b, store: s.Batching}, nil
+}
+
+func (s *syncedDatastore) DiskUsage(ctx context.Context) (uint64, error) {
+	return datastore.DiskUsage(ctx, s.Batching)
+}
+
+type syncedBatch struct {
+	datastore.Batch
+	store datastore.Batching
+}
+
+func (b *syncedBatch) Commit(ctx context.Context) error {
+	if err := b.Batch.Commit(ctx); err != nil {
+		return err
+	}
+
+	return b.store.Sync(ctx, datastore.NewKey("/"))
 }
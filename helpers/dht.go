@@ -0,0 +1,266 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ipns "github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p-kad-dht/dual"
+	record "github.com/libp2p/go-libp2p-record"
+	host "github.com/libp2p/go-libp2p/core/host"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	routing "github.com/libp2p/go-libp2p/core/routing"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// DHTMode sets whether a node answers DHT queries.
+type DHTMode int
+
+const (
+	// DHTModeAuto serves the DHT once the node is found publicly reachable.
+	DHTModeAuto DHTMode = iota
+	// DHTModeClient only queries the DHT.
+	DHTModeClient
+	// DHTModeServer always serves the DHT.
+	DHTModeServer
+)
+
+func (m DHTMode) String() string {
+	switch m {
+	case DHTModeAuto:
+		return "auto"
+	case DHTModeClient:
+		return "client"
+	case DHTModeServer:
+		return "server"
+	}
+
+	return fmt.Sprintf("dht-mode(%d)", int(m))
+}
+
+func (m DHTMode) option() (dht.Option, error) {
+	switch m {
+	case DHTModeAuto:
+		return dht.Mode(dht.ModeAuto), nil
+	case DHTModeClient:
+		return dht.Mode(dht.ModeClient), nil
+	case DHTModeServer:
+		return dht.Mode(dht.ModeServer), nil
+	}
+
+	return nil, fmt.Errorf("unknown dht mode %s", m)
+}
+
+// DHTTopology selects the DHTs a node runs.
+type DHTTopology int
+
+const (
+	// DHTDual runs a WAN DHT of public peers and a LAN DHT of private ones.
+	DHTDual DHTTopology = iota
+	// DHTWANOnly only runs the DHT of public peers.
+	DHTWANOnly
+	// DHTLANOnly only runs the DHT of private peers, compatible with the LAN
+	// side of dual nodes.
+	DHTLANOnly
+)
+
+func (t DHTTopology) String() string {
+	switch t {
+	case DHTDual:
+		return "dual"
+	case DHTWANOnly:
+		return "wan"
+	case DHTLANOnly:
+		return "lan"
+	}
+
+	return fmt.Sprintf("dht-topology(%d)", int(t))
+}
+
+// DHTConfig configures the DHT of a node. The zero value is the default
+// configuration: a dual DHT in auto mode on the /ipfs protocols.
+type DHTConfig struct {
+	Mode     DHTMode
+	Topology DHTTopology
+	// ProtocolPrefix replaces /ipfs in the DHT protocols, keeping the DHT of a
+	// private swarm apart from the IPFS one. Required to change BucketSize or
+	// Validators.
+	ProtocolPrefix protocol.ID
+	// BucketSize is the size of the routing table buckets. Defaults to 20.
+	BucketSize int
+	// Concurrency is the number of concurrent requests of a query. Defaults
+	// to DefaultDHTConcurrency.
+	Concurrency int
+	// Validators validate the records of their namespace, on top of the pk
+	// and ipns ones.
+	Validators map[string]record.Validator
+}
+
+// DefaultDHTConcurrency is the DHT query concurrency of nodes not configured
+// otherwise.
+var DefaultDHTConcurrency = 10
+
+// These mirror the WAN filter of the dual DHT.
+const (
+	maxPrefixCountPerCpl = 2
+	maxPrefixCount       = 3
+)
+
+// Validate checks the values of c.
+func (c DHTConfig) Validate() error {
+	if _, err := c.Mode.option(); err != nil {
+		return err
+	}
+
+	switch c.Topology {
+	case DHTDual, DHTWANOnly, DHTLANOnly:
+	default:
+		return fmt.Errorf("unknown dht topology %s", c.Topology)
+	}
+
+	if c.BucketSize < 0 || c.Concurrency < 0 {
+		return errors.New("dht bucket size and concurrency can not be negative")
+	}
+
+	if c.prefix() == dht.DefaultPrefix && (c.BucketSize != 0 || len(c.Validators) != 0) {
+		return fmt.Errorf("dht bucket size and validators can only be changed with a protocol prefix other than %s", dht.DefaultPrefix)
+	}
+
+	for ns, v := range c.Validators {
+		if ns == "pk" || ns == "ipns" {
+			return fmt.Errorf("dht namespace `%s` is reserved", ns)
+		}
+		if v == nil {
+			return fmt.Errorf("validator of dht namespace `%s` is nil", ns)
+		}
+	}
+
+	return nil
+}
+
+// options returns the options common to every DHT of the node.
+func (c DHTConfig) options(h host.Host, store datastore.Batching) ([]dht.Option, error) {
+	mode, err := c.Mode.option()
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := c.Concurrency
+	if concurrency == 0 {
+		concurrency = DefaultDHTConcurrency
+	}
+
+	opts := []dht.Option{
+		dht.NamespacedValidator("pk", record.PublicKeyValidator{}),
+		dht.NamespacedValidator("ipns", ipns.Validator{KeyBook: h.Peerstore()}),
+		dht.Concurrency(concurrency),
+		mode,
+		dht.Datastore(namespace.Wrap(store, datastore.NewKey(dhtNamespace))),
+	}
+
+	for ns, v := range c.Validators {
+		opts = append(opts, dht.NamespacedValidator(ns, v))
+	}
+
+	if c.BucketSize != 0 {
+		opts = append(opts, dht.BucketSize(c.BucketSize))
+	}
+
+	return opts, nil
+}
+
+func (c DHTConfig) prefix() protocol.ID {
+	if c.ProtocolPrefix == "" {
+		return dht.DefaultPrefix
+	}
+
+	return c.ProtocolPrefix
+}
+
+// NewDHT creates the DHT of h as configured by c. It is a *dual.DHT unless
+// the topology is WAN or LAN only, in which case it is a *dht.IpfsDHT.
+func NewDHT(ctx context.Context, h host.Host, store datastore.Batching, c DHTConfig, bootstrapPeers ...peer.AddrInfo) (routing.Routing, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts, err := c.options(h, store)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := c.prefix()
+	wan := []dht.Option{
+		dht.ProtocolPrefix(prefix),
+		dht.QueryFilter(dht.PublicQueryFilter),
+		dht.RoutingTableFilter(dht.PublicRoutingTableFilter),
+		dht.RoutingTablePeerDiversityFilter(dht.NewRTPeerDiversityFilter(h, maxPrefixCountPerCpl, maxPrefixCount)),
+		dht.AddressFilter(func(addrs []ma.Multiaddr) []ma.Multiaddr { return ma.FilterAddrs(addrs, manet.IsPublicAddr) }),
+	}
+	lan := []dht.Option{
+		dht.ProtocolPrefix(prefix + dual.LanExtension),
+		dht.QueryFilter(dht.PrivateQueryFilter),
+		dht.RoutingTableFilter(dht.PrivateRoutingTableFilter),
+		dht.AddressFilter(func(addrs []ma.Multiaddr) []ma.Multiaddr {
+			return ma.FilterAddrs(addrs, func(a ma.Multiaddr) bool { return !manet.IsIPLoopback(a) })
+		}),
+	}
+
+	if len(bootstrapPeers) != 0 {
+		wan = append(wan, dht.BootstrapPeers(bootstrapPeers...))
+	}
+
+	var single []dht.Option
+	switch c.Topology {
+	case DHTWANOnly:
+		single = append(opts, wan...)
+	case DHTLANOnly:
+		single = append(opts, lan...)
+		if len(bootstrapPeers) != 0 {
+			single = append(single, dht.BootstrapPeers(bootstrapPeers...))
+		}
+	}
+
+	if single != nil {
+		d, err := dht.New(ctx, h, single...)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+
+	// the prefix is set per DHT, after the filters of dual.New
+	d, err := dual.New(ctx, h,
+		dual.DHTOption(opts...),
+		dual.WanDHTOption(wan...),
+		dual.LanDHTOption(lan...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// closestPeers is implemented by DHTs able to find the peers closest to a key.
+type closestPeers interface {
+	GetClosestPeers(ctx context.Context, key string) ([]peer.ID, error)
+}
+
+// wanRouting returns the DHT of r to look for relays in, if any.
+func wanRouting(r routing.Routing) closestPeers {
+	switch d := r.(type) {
+	case *dual.DHT:
+		return d.WAN
+	case closestPeers:
+		return d
+	}
+
+	return nil
+}
@@ -2,17 +2,13 @@ package helpers
 
 import (
 	"context"
+	"io"
 	"sync"
 	"time"
 
-	ipns "github.com/ipfs/boxo/ipns"
 	"github.com/ipfs/go-datastore"
-	"github.com/ipfs/go-datastore/namespace"
 
 	"github.com/libp2p/go-libp2p"
-	dht "github.com/libp2p/go-libp2p-kad-dht"
-	"github.com/libp2p/go-libp2p-kad-dht/dual"
-	record "github.com/libp2p/go-libp2p-record"
 	p2pConfig "github.com/libp2p/go-libp2p/config"
 	crypto "github.com/libp2p/go-libp2p/core/crypto"
 	host "github.com/libp2p/go-libp2p/core/host"
@@ -21,8 +17,8 @@ import (
 	pnet "github.com/libp2p/go-libp2p/core/pnet"
 	routing "github.com/libp2p/go-libp2p/core/routing"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
+	"github.com/libp2p/go-libp2p/p2p/host/observedaddrs"
 	connmgr "github.com/libp2p/go-libp2p/p2p/net/connmgr"
-	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
 
 	tcp "github.com/libp2p/go-libp2p/p2p/transport/tcp"
@@ -45,10 +41,20 @@ func init() {
 	// affects dockerized setups mostly. This may announce non-dialable
 	// NATed addresses too eagerly, but they should progressively be
 	// cleaned up.
-	identify.ActivationThresh = 1
+	observedaddrs.ActivationThresh = 1
 	network.DialPeerTimeout = 120 * time.Second
 }
 
+// Libp2pOptionsCommon are the options of every node, transports and security
+// aside. See Transports.Options and SecurityOptions.
+var Libp2pOptionsCommon = []libp2p.Option{
+	libp2p.Ping(true),
+	libp2p.EnableRelay(),
+	libp2p.DefaultMuxers,
+}
+
+// Libp2pOptionsBase are the common options with the TCP transport and TLS
+// security only.
 var Libp2pOptionsBase = []libp2p.Option{
 	libp2p.Ping(true),
 	libp2p.EnableRelay(),
@@ -144,9 +150,23 @@ func SetupLibp2p(
 	bootstrapPeerFunc func() []peer.AddrInfo,
 	opts ...libp2p.Option,
 ) (host.Host, routing.Routing, error) {
+	return SetupLibp2pWithDHT(ctx, hostKey, secret, listenAddrs, ds, bootstrapPeerFunc, DHTConfig{}, opts...)
+}
+
+// SetupLibp2pWithDHT is SetupLibp2p with the DHT configured by dhtConfig.
+func SetupLibp2pWithDHT(
+	ctx context.Context,
+	hostKey crypto.PrivKey,
+	secret pnet.PSK,
+	listenAddrs []string,
+	ds datastore.Batching,
+	bootstrapPeerFunc func() []peer.AddrInfo,
+	dhtConfig DHTConfig,
+	opts ...libp2p.Option,
+) (host.Host, routing.Routing, error) {
 
 	var h host.Host
-	var idht *dual.DHT
+	var idht routing.Routing
 	var err error
 
 	// a channel to wait until these variables have been set
@@ -159,7 +179,7 @@ func SetupLibp2p(
 		return h
 	}
 
-	dhtGetter := func() *dual.DHT {
+	dhtGetter := func() routing.Routing {
 		<-hostAndDHTReady // closed when we finish NewClusterHost
 		return idht
 	}
@@ -169,12 +189,7 @@ func SetupLibp2p(
 		libp2p.ListenAddrStrings(listenAddrs...),
 		libp2p.PrivateNetwork(secret),
 		libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
-			extraopts := make([]dual.Option, 0)
-			bpeer := bootstrapPeerFunc()
-			if len(bpeer) != 0 {
-				extraopts = append(extraopts, dual.WanDHTOption(dht.BootstrapPeers(bpeer...)))
-			}
-			idht, err = newDHT(ctx, h, ds, extraopts...)
+			idht, err = NewDHT(ctx, h, ds, dhtConfig, bootstrapPeerFunc()...)
 			return idht, err
 		}),
 		libp2p.EnableAutoRelayWithPeerSource(newPeerSource(hostGetter, dhtGetter)),
@@ -182,28 +197,16 @@ func SetupLibp2p(
 
 	h, err = libp2p.New(opts...)
 	if err != nil {
+		// the dht may be built before the host fails to start
+		if c, ok := idht.(io.Closer); ok {
+			c.Close()
+		}
 		return nil, nil, err
 	}
 
 	return h, idht, nil
 }
 
-func newDHT(ctx context.Context, h host.Host, store datastore.Batching, extraopts ...dual.Option) (*dual.DHT, error) {
-	dhtDatastore := namespace.Wrap(store, datastore.NewKey(dhtNamespace))
-
-	opts := []dual.Option{
-		dual.DHTOption(dht.NamespacedValidator("pk", record.PublicKeyValidator{})),
-		dual.DHTOption(dht.NamespacedValidator("ipns", ipns.Validator{KeyBook: h.Peerstore()})),
-		dual.DHTOption(dht.Concurrency(10)),
-		dual.DHTOption(dht.Mode(dht.ModeAuto)),
-		dual.DHTOption(dht.Datastore(dhtDatastore)),
-	}
-
-	opts = append(opts, extraopts...)
-
-	return dual.New(ctx, h, opts...)
-}
-
 // Inspired in Kubo's
 // https://github.com/ipfs/go-ipfs/blob/9327ee64ce96ca6da29bb2a099e0e0930b0d9e09/core/node/libp2p/relay.go#L79-L103
 // and https://github.com/ipfs/go-ipfs/blob/9327ee64ce96ca6da29bb2a099e0e0930b0d9e09/core/node/libp2p/routing.go#L242-L317
@@ -212,7 +215,7 @@ func newDHT(ctx context.Context, h host.Host, store datastore.Batching, extraopt
 //   - We return the peers from that lookup.
 //   - No need to do it async, since we have to wait for the full lookup to
 //     return anyways. We put them on a buffered channel and be done.
-func newPeerSource(hostGetter func() host.Host, dhtGetter func() *dual.DHT) autorelay.PeerSource {
+func newPeerSource(hostGetter func() host.Host, dhtGetter func() routing.Routing) autorelay.PeerSource {
 	return func(ctx context.Context, numPeers int) <-chan peer.AddrInfo {
 		// make a channel to return, and put items from numPeers on
 		// that channel up to numPeers. Then close it.
@@ -225,13 +228,13 @@ func newPeerSource(hostGetter func() host.Host, dhtGetter func() *dual.DHT) auto
 		if h == nil { // context canceled etc.
 			return r
 		}
-		idht := dhtGetter()
+		idht := wanRouting(dhtGetter())
 		if idht == nil { // context canceled etc.
 			return r
 		}
 
 		// length of closest peers is K.
-		closestPeers, err := idht.WAN.GetClosestPeers(ctx, h.ID().String())
+		closestPeers, err := idht.GetClosestPeers(ctx, h.ID().String())
 		if err != nil { // Bail out. Usually a "no peers found".
 			return r
 		}
@@ -257,43 +260,57 @@ func newPeerSource(hostGetter func() host.Host, dhtGetter func() *dual.DHT) auto
 	}
 }
 
-// Bootstrap is an optional helper to connect to the given peers and bootstrap
-// the Peer DHT (and Bitswap). This is a best-effort function. Errors are only
-// logged and a warning is printed when less than half of the given peers
-// could be contacted. It is fine to pass a list where some peers will not be
-// reachable.
-func Bootstrap(ctx context.Context, h host.Host, dht routing.Routing, peers []peer.AddrInfo) ([]peer.AddrInfo, error) {
-	connected := make(chan struct{})
+// BootstrapFailure is a bootstrap peer that could not be reached.
+type BootstrapFailure struct {
+	Peer peer.AddrInfo
+	Err  error
+}
+
+// BootstrapReport is the outcome of BootstrapPeers. Peers are listed in the
+// order they were given.
+type BootstrapReport struct {
+	Connected []peer.AddrInfo
+	Failed    []BootstrapFailure
+}
+
+// BootstrapPeers connects to the given peers and bootstraps the Peer DHT (and
+// Bitswap). Unreachable peers are listed in the report, the returned error is
+// the one of the DHT bootstrap.
+func BootstrapPeers(ctx context.Context, h host.Host, dht routing.Routing, peers []peer.AddrInfo) (BootstrapReport, error) {
+	errs := make([]error, len(peers))
 
 	var wg sync.WaitGroup
-	for _, pinfo := range peers {
+	for i, pinfo := range peers {
 		wg.Add(1)
-		go func(pinfo peer.AddrInfo) {
+		go func(i int, pinfo peer.AddrInfo) {
 			defer wg.Done()
-			err := h.Connect(ctx, pinfo)
-			if err != nil {
+			if errs[i] = h.Connect(ctx, pinfo); errs[i] != nil {
 				return
 			}
 			h.ConnManager().TagPeer(pinfo.ID, "bootstrap", 42)
-
-			connected <- struct{}{}
-		}(pinfo)
+		}(i, pinfo)
 	}
 
-	go func() {
-		wg.Wait()
-		close(connected)
-	}()
+	wg.Wait()
 
-	i := 0
-	for range connected {
-		i++
+	var report BootstrapReport
+	for i, pinfo := range peers {
+		if errs[i] != nil {
+			report.Failed = append(report.Failed, BootstrapFailure{Peer: pinfo, Err: errs[i]})
+		} else {
+			report.Connected = append(report.Connected, pinfo)
+		}
 	}
 
-	err := dht.Bootstrap(ctx)
-	if err != nil {
-		return peers, err
-	}
+	return report, dht.Bootstrap(ctx)
+}
 
-	return peers, nil
+// Bootstrap is an optional helper to connect to the given peers and bootstrap
+// the Peer DHT (and Bitswap). This is a best-effort function: unreachable
+// peers are ignored and the given list is returned as is.
+//
+// Deprecated: use BootstrapPeers, which reports the reached peers.
+func Bootstrap(ctx context.Context, h host.Host, dht routing.Routing, peers []peer.AddrInfo) ([]peer.AddrInfo, error) {
+	_, err := BootstrapPeers(ctx, h, dht, peers)
+	return peers, err
 }
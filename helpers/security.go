@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
+)
+
+// Security is a connection security protocol.
+type Security int
+
+const (
+	SecurityTLS Security = iota + 1
+	SecurityNoise
+)
+
+// DefaultSecurity is the security of nodes not configured otherwise.
+var DefaultSecurity = []Security{SecurityTLS}
+
+func (s Security) String() string {
+	switch s {
+	case SecurityTLS:
+		return libp2ptls.ID
+	case SecurityNoise:
+		return noise.ID
+	}
+
+	return fmt.Sprintf("security(%d)", int(s))
+}
+
+// SecurityOptions returns the libp2p options enabling protocols, in order of
+// preference. The preference of the dialing side wins.
+func SecurityOptions(protocols ...Security) ([]libp2p.Option, error) {
+	if len(protocols) == 0 {
+		return nil, errors.New("no security protocol enabled")
+	}
+
+	seen := make(map[Security]bool, len(protocols))
+	opts := make([]libp2p.Option, 0, len(protocols))
+	for _, s := range protocols {
+		if seen[s] {
+			return nil, fmt.Errorf("security protocol %s is set twice", s)
+		}
+		seen[s] = true
+
+		switch s {
+		case SecurityTLS:
+			opts = append(opts, libp2p.Security(libp2ptls.ID, libp2ptls.New))
+		case SecurityNoise:
+			opts = append(opts, libp2p.Security(noise.ID, noise.New))
+		default:
+			return nil, fmt.Errorf("unknown security protocol %s", s)
+		}
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,132 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p"
+	libp2pquic "github.com/libp2p/go-libp2p/p2p/transport/quic"
+	tcp "github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
+	webtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Transports is a set of libp2p transports.
+type Transports uint
+
+const (
+	TransportTCP Transports = 1 << iota
+	TransportQUIC
+	TransportWebSocket
+	TransportWebTransport
+)
+
+// DefaultTransports are the transports of nodes not configured otherwise.
+const DefaultTransports = TransportTCP
+
+var transportNames = []struct {
+	transport Transports
+	name      string
+}{
+	{TransportTCP, "tcp"},
+	{TransportQUIC, "quic"},
+	{TransportWebSocket, "websocket"},
+	{TransportWebTransport, "webtransport"},
+}
+
+func (t Transports) String() string {
+	var names []string
+	for _, n := range transportNames {
+		if t&n.transport != 0 {
+			names = append(names, n.name)
+			t &^= n.transport
+		}
+	}
+
+	if t != 0 {
+		names = append(names, fmt.Sprintf("transport(%d)", uint(t)))
+	}
+
+	return strings.Join(names, "|")
+}
+
+// Options returns the libp2p options enabling exactly the transports of t.
+func (t Transports) Options() ([]libp2p.Option, error) {
+	if t == 0 {
+		return nil, errors.New("no transport enabled")
+	}
+
+	opts := []libp2p.Option{libp2p.NoTransports}
+	if t&TransportTCP != 0 {
+		opts = append(opts, libp2p.Transport(tcp.NewTCPTransport))
+	}
+	if t&TransportQUIC != 0 {
+		opts = append(opts, libp2p.Transport(libp2pquic.NewTransport))
+	}
+	if t&TransportWebSocket != 0 {
+		opts = append(opts, libp2p.Transport(websocket.New))
+	}
+	if t&TransportWebTransport != 0 {
+		opts = append(opts, libp2p.Transport(webtransport.New))
+	}
+
+	return opts, nil
+}
+
+// PrivateNetwork reports whether all transports of t support private networks.
+// QUIC and WebTransport do not.
+func (t Transports) PrivateNetwork() bool {
+	return t&(TransportQUIC|TransportWebTransport) == 0
+}
+
+// TransportOf returns the transport an address is handled by.
+func TransportOf(addr multiaddr.Multiaddr) (Transports, error) {
+	// the last transport protocol wins: /tcp/<port>/ws is WebSocket
+	var (
+		transport Transports
+		found     bool
+	)
+	multiaddr.ForEach(addr, func(c multiaddr.Component) bool {
+		switch c.Protocol().Code {
+		case multiaddr.P_TCP:
+			transport, found = TransportTCP, true
+		case multiaddr.P_QUIC_V1:
+			transport, found = TransportQUIC, true
+		case multiaddr.P_WS, multiaddr.P_WSS:
+			transport = TransportWebSocket
+		case multiaddr.P_WEBTRANSPORT:
+			transport = TransportWebTransport
+		}
+		return true
+	})
+
+	if !found {
+		return 0, fmt.Errorf("no transport handles `%s`", addr)
+	}
+
+	return transport, nil
+}
+
+// ValidateListen checks that every address of addrs is handled by one of the
+// transports of t.
+func (t Transports) ValidateListen(addrs []string) error {
+	for _, a := range addrs {
+		addr, err := multiaddr.NewMultiaddr(a)
+		if err != nil {
+			return fmt.Errorf("parsing listen address `%s` failed with: %w", a, err)
+		}
+
+		transport, err := TransportOf(addr)
+		if err != nil {
+			return err
+		}
+
+		if t&transport == 0 {
+			return fmt.Errorf("listen address `%s` needs the %s transport, enabled transports are %s", a, transport, t)
+		}
+	}
+
+	return nil
+}
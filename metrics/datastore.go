@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// Datastore measures the operations of a datastore.
+type Datastore struct {
+	datastore.Batching
+	metrics Metrics
+}
+
+var (
+	_ datastore.Batching            = (*Datastore)(nil)
+	_ datastore.PersistentDatastore = (*Datastore)(nil)
+)
+
+// WrapDatastore returns store reporting its operations to m.
+func WrapDatastore(store datastore.Batching, m Metrics) *Datastore {
+	return &Datastore{Batching: store, metrics: m}
+}
+
+// Unwrap returns the measured datastore.
+func (d *Datastore) Unwrap() datastore.Batching {
+	return d.Batching
+}
+
+func (d *Datastore) done(op string, start time.Time, err error) {
+	if errors.Is(err, datastore.ErrNotFound) {
+		err = nil
+	}
+
+	d.metrics.DatastoreOp(op, time.Since(start), err)
+}
+
+func (d *Datastore) Get(ctx context.Context, key datastore.Key) ([]byte, error) {
+	start := time.Now()
+	value, err := d.Batching.Get(ctx, key)
+	d.done("get", start, err)
+	return value, err
+}
+
+func (d *Datastore) Has(ctx context.Context, key datastore.Key) (bool, error) {
+	start := time.Now()
+	exists, err := d.Batching.Has(ctx, key)
+	d.done("has", start, err)
+	return exists, err
+}
+
+func (d *Datastore) GetSize(ctx context.Context, key datastore.Key) (int, error) {
+	start := time.Now()
+	size, err := d.Batching.GetSize(ctx, key)
+	d.done("get_size", start, err)
+	return size, err
+}
+
+func (d *Datastore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	start := time.Now()
+	results, err := d.Batching.Query(ctx, q)
+	d.done("query", start, err)
+	return results, err
+}
+
+func (d *Datastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	start := time.Now()
+	err := d.Batching.Put(ctx, key, value)
+	d.done("put", start, err)
+	return err
+}
+
+func (d *Datastore) Delete(ctx context.Context, key datastore.Key) error {
+	start := time.Now()
+	err := d.Batching.Delete(ctx, key)
+	d.done("delete", start, err)
+	return err
+}
+
+func (d *Datastore) Sync(ctx context.Context, prefix datastore.Key) error {
+	start := time.Now()
+	err := d.Batching.Sync(ctx, prefix)
+	d.done("sync", start, err)
+	return err
+}
+
+func (d *Datastore) Batch(ctx context.Context) (datastore.Batch, error) {
+	b, err := d.Batching.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch{Batch: b, store: d}, nil
+}
+
+// batch measures the commits of a batch.
+type batch struct {
+	datastore.Batch
+	store *Datastore
+}
+
+func (b *batch) Commit(ctx context.Context) error {
+	start := time.Now()
+	err := b.Batch.Commit(ctx)
+	b.store.done("batch_commit", start, err)
+	return err
+}
+
+// DiskUsage returns the disk usage of the measured datastore, zero if it does
+// not report one.
+func (d *Datastore) DiskUsage(ctx context.Context) (uint64, error) {
+	return datastore.DiskUsage(ctx, d.Batching)
+}
@@ -0,0 +1,53 @@
+// Package metrics defines what nodes measure, and exports it to Prometheus.
+package metrics
+
+import (
+	"time"
+)
+
+// Direction tells who initiated a connection, stream, command or message.
+type Direction string
+
+const (
+	// Inbound is initiated by a remote peer.
+	Inbound Direction = "inbound"
+	// Outbound is initiated by the node.
+	Outbound Direction = "outbound"
+)
+
+// Metrics receives the measures of a node. Implementations must be safe for
+// concurrent use.
+type Metrics interface {
+	// Connection is called when a connection opens or closes.
+	Connection(dir Direction, opened bool)
+	// Stream is called when a stream of protocol opens.
+	Stream(protocol string, dir Direction)
+	// Command is called when a command completes, err being its error if it
+	// failed.
+	Command(name string, dir Direction, took time.Duration, err error)
+	// PeeringReconnect is called after the peering service tried to reconnect
+	// to a peer.
+	PeeringReconnect(err error)
+	// PubSubMessage is called for messages published by the node, outbound,
+	// and delivered to it, inbound. size is the size of the message data.
+	PubSubMessage(topic string, dir Direction, size int)
+	// DatastoreOp is called when an operation on the node datastore
+	// completes.
+	DatastoreOp(op string, took time.Duration, err error)
+	// FileOp is called when adding, getting or deleting a file completes.
+	FileOp(op string, took time.Duration, err error)
+}
+
+// Noop discards all measures. It is the metrics of nodes created without
+// any.
+type Noop struct{}
+
+var _ Metrics = Noop{}
+
+func (Noop) Connection(Direction, bool)                      {}
+func (Noop) Stream(string, Direction)                        {}
+func (Noop) Command(string, Direction, time.Duration, error) {}
+func (Noop) PeeringReconnect(error)                          {}
+func (Noop) PubSubMessage(string, Direction, int)            {}
+func (Noop) DatastoreOp(string, time.Duration, error)        {}
+func (Noop) FileOp(string, time.Duration, error)             {}
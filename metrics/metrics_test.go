@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taubyte/p2p/datastores/mem"
+)
+
+func scrape(t *testing.T, m *Prometheus) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("reading metrics failed with: %s", err)
+	}
+
+	return string(body)
+}
+
+func TestPrometheus(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := NewPrometheus(registry)
+	if err != nil {
+		t.Errorf("NewPrometheus returned error `%s`", err.Error())
+		return
+	}
+
+	// nodes may share a registry
+	shared, err := NewPrometheus(registry)
+	if err != nil {
+		t.Errorf("NewPrometheus on a used registry returned error `%s`", err.Error())
+		return
+	}
+
+	m.Connection(Inbound, true)
+	shared.Connection(Inbound, true)
+	m.Connection(Inbound, false)
+	m.Stream("/hello/1.0", Outbound)
+	m.Command("hi", Outbound, time.Millisecond, nil)
+	m.Command("hi", Outbound, time.Millisecond, errors.New("failed"))
+	m.PeeringReconnect(nil)
+	m.PeeringReconnect(errors.New("unreachable"))
+	m.PubSubMessage("topic", Outbound, 5)
+	m.FileOp("add", time.Millisecond, nil)
+
+	body := scrape(t, m)
+	for _, line := range []string{
+		`taubyte_p2p_connections{direction="inbound"} 1`,
+		`taubyte_p2p_connections_total{direction="inbound"} 2`,
+		`taubyte_p2p_streams_total{direction="outbound",protocol="/hello/1.0"} 1`,
+		`taubyte_p2p_command_duration_seconds_count{command="hi",direction="outbound"} 2`,
+		`taubyte_p2p_command_errors_total{command="hi",direction="outbound"} 1`,
+		`taubyte_p2p_peering_reconnects_total{result="failure"} 1`,
+		`taubyte_p2p_peering_reconnects_total{result="success"} 1`,
+		`taubyte_p2p_pubsub_messages_total{direction="outbound",topic="topic"} 1`,
+		`taubyte_p2p_pubsub_bytes_total{direction="outbound",topic="topic"} 5`,
+		`taubyte_p2p_file_op_duration_seconds_count{op="add"} 1`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("missing `%s` in:\n%s", line, body)
+		}
+	}
+}
+
+func TestDatastore(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := NewPrometheus(nil)
+	if err != nil {
+		t.Errorf("NewPrometheus returned error `%s`", err.Error())
+		return
+	}
+
+	store := WrapDatastore(mem.New(), m)
+	key := datastore.NewKey("/key")
+
+	if err = store.Put(ctx, key, []byte("value")); err != nil {
+		t.Errorf("Put returned error `%s`", err.Error())
+		return
+	}
+
+	if _, err = store.Get(ctx, datastore.NewKey("/missing")); !errors.Is(err, datastore.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	b, err := store.Batch(ctx)
+	if err != nil {
+		t.Errorf("Batch returned error `%s`", err.Error())
+		return
+	}
+	b.Delete(ctx, key)
+	if err = b.Commit(ctx); err != nil {
+		t.Errorf("Commit returned error `%s`", err.Error())
+	}
+
+	body := scrape(t, m)
+	for _, line := range []string{
+		`taubyte_p2p_datastore_op_duration_seconds_count{op="put"} 1`,
+		`taubyte_p2p_datastore_op_duration_seconds_count{op="get"} 1`,
+		`taubyte_p2p_datastore_op_duration_seconds_count{op="batch_commit"} 1`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("missing `%s` in:\n%s", line, body)
+		}
+	}
+
+	if strings.Contains(body, "taubyte_p2p_datastore_errors_total") {
+		t.Error("missing keys are counted as errors")
+	}
+}
@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of the exported metrics.
+const Namespace = "taubyte_p2p"
+
+// Prometheus exports measures to a Prometheus registry.
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	connections      *prometheus.GaugeVec
+	connectionsTotal *prometheus.CounterVec
+	streams          *prometheus.CounterVec
+	commands         *prometheus.HistogramVec
+	commandErrors    *prometheus.CounterVec
+	reconnects       *prometheus.CounterVec
+	pubsubMessages   *prometheus.CounterVec
+	pubsubBytes      *prometheus.CounterVec
+	datastoreOps     *prometheus.HistogramVec
+	datastoreErrors  *prometheus.CounterVec
+	fileOps          *prometheus.HistogramVec
+	fileErrors       *prometheus.CounterVec
+}
+
+var _ Metrics = (*Prometheus)(nil)
+
+// NewPrometheus registers the node metrics in registry, a new one if nil.
+// Nodes sharing a registry share their metrics.
+func NewPrometheus(registry *prometheus.Registry) (*Prometheus, error) {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+
+	m := &Prometheus{registry: registry}
+
+	var err error
+	if m.connections, err = register(registry, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "connections",
+		Help:      "Open connections.",
+	}, []string{"direction"})); err != nil {
+		return nil, err
+	}
+
+	if m.connectionsTotal, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "connections_total",
+		Help:      "Opened connections.",
+	}, []string{"direction"})); err != nil {
+		return nil, err
+	}
+
+	if m.streams, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "streams_total",
+		Help:      "Opened streams per protocol.",
+	}, []string{"protocol", "direction"})); err != nil {
+		return nil, err
+	}
+
+	if m.commands, err = register(registry, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "command_duration_seconds",
+		Help:      "Latency of commands, sent or handled.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command", "direction"})); err != nil {
+		return nil, err
+	}
+
+	if m.commandErrors, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "command_errors_total",
+		Help:      "Failed commands, sent or handled.",
+	}, []string{"command", "direction"})); err != nil {
+		return nil, err
+	}
+
+	if m.reconnects, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "peering_reconnects_total",
+		Help:      "Reconnect attempts of the peering service.",
+	}, []string{"result"})); err != nil {
+		return nil, err
+	}
+
+	if m.pubsubMessages, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pubsub_messages_total",
+		Help:      "Pubsub messages published or delivered per topic.",
+	}, []string{"topic", "direction"})); err != nil {
+		return nil, err
+	}
+
+	if m.pubsubBytes, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pubsub_bytes_total",
+		Help:      "Data of pubsub messages published or delivered per topic.",
+	}, []string{"topic", "direction"})); err != nil {
+		return nil, err
+	}
+
+	if m.datastoreOps, err = register(registry, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "datastore_op_duration_seconds",
+		Help:      "Latency of datastore operations.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+	}, []string{"op"})); err != nil {
+		return nil, err
+	}
+
+	if m.datastoreErrors, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "datastore_errors_total",
+		Help:      "Failed datastore operations, missing keys excluded.",
+	}, []string{"op"})); err != nil {
+		return nil, err
+	}
+
+	if m.fileOps, err = register(registry, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "file_op_duration_seconds",
+		Help:      "Latency of file operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})); err != nil {
+		return nil, err
+	}
+
+	if m.fileErrors, err = register(registry, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "file_errors_total",
+		Help:      "Failed file operations.",
+	}, []string{"op"})); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// register registers c in registry, or returns the collector already
+// registered in its place.
+func register[C prometheus.Collector](registry *prometheus.Registry, c C) (C, error) {
+	err := registry.Register(c)
+
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		if existing, ok := registered.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+
+	return c, err
+}
+
+// Registry returns the registry of the metrics.
+func (m *Prometheus) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics of the registry, libp2p ones included when the
+// node was created with them, in the Prometheus text format.
+func (m *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Prometheus) Connection(dir Direction, opened bool) {
+	if opened {
+		m.connections.WithLabelValues(string(dir)).Inc()
+		m.connectionsTotal.WithLabelValues(string(dir)).Inc()
+	} else {
+		m.connections.WithLabelValues(string(dir)).Dec()
+	}
+}
+
+func (m *Prometheus) Stream(protocol string, dir Direction) {
+	m.streams.WithLabelValues(protocol, string(dir)).Inc()
+}
+
+func (m *Prometheus) Command(name string, dir Direction, took time.Duration, err error) {
+	m.commands.WithLabelValues(name, string(dir)).Observe(took.Seconds())
+	if err != nil {
+		m.commandErrors.WithLabelValues(name, string(dir)).Inc()
+	}
+}
+
+func (m *Prometheus) PeeringReconnect(err error) {
+	m.reconnects.WithLabelValues(result(err)).Inc()
+}
+
+func (m *Prometheus) PubSubMessage(topic string, dir Direction, size int) {
+	m.pubsubMessages.WithLabelValues(topic, string(dir)).Inc()
+	m.pubsubBytes.WithLabelValues(topic, string(dir)).Add(float64(size))
+}
+
+func (m *Prometheus) DatastoreOp(op string, took time.Duration, err error) {
+	m.datastoreOps.WithLabelValues(op).Observe(took.Seconds())
+	if err != nil {
+		m.datastoreErrors.WithLabelValues(op).Inc()
+	}
+}
+
+func (m *Prometheus) FileOp(op string, took time.Duration, err error) {
+	m.fileOps.WithLabelValues(op).Observe(took.Seconds())
+	if err != nil {
+		m.fileErrors.WithLabelValues(op).Inc()
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+
+	return "success"
+}
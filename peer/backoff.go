@@ -0,0 +1,145 @@
+package peer
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy computes the delays between peering reconnect attempts.
+type BackoffPolicy interface {
+	// Next returns the delay before the next attempt, given the previous
+	// delay. prev is zero for the first attempt after a disconnection.
+	Next(prev time.Duration) time.Duration
+}
+
+// minBackoffDelay is the shortest delay between attempts, policies never go
+// under it so that a misconfigured one cannot spin on reconnects.
+const minBackoffDelay = 100 * time.Millisecond
+
+// clampDelay raises d to minBackoffDelay.
+func clampDelay(d time.Duration) time.Duration {
+	if d < minBackoffDelay {
+		return minBackoffDelay
+	}
+	return d
+}
+
+type exponentialBackoff struct {
+	initial time.Duration
+	max     time.Duration
+	factor  float64
+	jitter  float64
+}
+
+// ExponentialBackoff multiplies the delay by factor on every attempt, starting
+// from initial and up to max. Every delay is randomly reduced by up to jitter
+// (between 0 and 1) of its value. Delays are never shorter than 100ms.
+func ExponentialBackoff(initial, max time.Duration, factor, jitter float64) BackoffPolicy {
+	initial = clampDelay(initial)
+	if max < initial {
+		max = initial
+	}
+
+	if factor < 1 {
+		factor = 1
+	}
+
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+
+	return &exponentialBackoff{initial: initial, max: max, factor: factor, jitter: jitter}
+}
+
+func (b *exponentialBackoff) Next(prev time.Duration) time.Duration {
+	next := b.initial
+	if prev > 0 {
+		next = time.Duration(float64(prev) * b.factor)
+	}
+
+	if next > b.max {
+		next = b.max
+	}
+
+	if b.jitter > 0 && next > 0 {
+		next -= time.Duration(rand.Int63n(int64(float64(next)*b.jitter) + 1))
+	}
+
+	return clampDelay(next)
+}
+
+type constantBackoff time.Duration
+
+// ConstantBackoff always waits delay between attempts, or 100ms if delay is
+// shorter.
+func ConstantBackoff(delay time.Duration) BackoffPolicy {
+	return constantBackoff(clampDelay(delay))
+}
+
+func (b constantBackoff) Next(time.Duration) time.Duration {
+	return time.Duration(b)
+}
+
+type decorrelatedJitterBackoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+// DecorrelatedJitterBackoff picks every delay randomly between base and three
+// times the previous delay, up to max. It spreads reconnections of many peers
+// better than ExponentialBackoff. Delays are never shorter than 100ms.
+func DecorrelatedJitterBackoff(base, max time.Duration) BackoffPolicy {
+	base = clampDelay(base)
+	if max < base {
+		max = base
+	}
+
+	return &decorrelatedJitterBackoff{base: base, max: max}
+}
+
+func (b *decorrelatedJitterBackoff) Next(prev time.Duration) time.Duration {
+	if prev < b.base {
+		prev = b.base
+	}
+
+	next := b.base
+	if span := 3*prev - b.base; span > 0 {
+		next += time.Duration(rand.Int63n(int64(span)))
+	}
+
+	if next > b.max {
+		next = b.max
+	}
+
+	return next
+}
+
+type defaultBackoff struct{}
+
+func (defaultBackoff) Next(prev time.Duration) time.Duration {
+	next := prev
+	if next < initialDelay {
+		next = initialDelay
+	}
+
+	if next < maxBackoff {
+		next += next/2 + time.Duration(rand.Int63n(int64(next)))
+	}
+
+	// If we've gone over the max backoff, reduce it under the max.
+	if next > maxBackoff {
+		next = maxBackoff
+		// randomize the backoff a bit (10%).
+		next -= time.Duration(rand.Int63n(int64(maxBackoff) * maxBackoffJitter / 100))
+	}
+
+	return next
+}
+
+// DefaultBackoffPolicy is used for peering peers that were added without a
+// policy, unless another one is set with WithPeeringBackoff. It starts from
+// initialDelay and grows every delay by 1.5 to 2.5 times, up to maxBackoff
+// less a random jitter of up to maxBackoffJitter percent.
+var DefaultBackoffPolicy BackoffPolicy = defaultBackoff{}
@@ -0,0 +1,89 @@
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(time.Second, 5*time.Second, 2, 0)
+
+	var delay time.Duration
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if delay = b.Next(delay); delay != expected {
+			t.Errorf("expected %s got %s", expected, delay)
+			return
+		}
+	}
+
+	b = ExponentialBackoff(time.Minute, time.Hour, 2, 0.1)
+	for i := 0; i < 100; i++ {
+		if delay = b.Next(0); delay < 54*time.Second || delay > time.Minute {
+			t.Errorf("delay %s is out of the jitter range", delay)
+			return
+		}
+	}
+}
+
+func TestConstantBackoff(t *testing.T) {
+	b := ConstantBackoff(3 * time.Second)
+	if b.Next(0) != 3*time.Second || b.Next(time.Hour) != 3*time.Second {
+		t.Error("constant backoff is not constant")
+	}
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	b := DecorrelatedJitterBackoff(time.Second, time.Minute)
+
+	var delay time.Duration
+	for i := 0; i < 100; i++ {
+		prev := delay
+		if prev < time.Second {
+			prev = time.Second
+		}
+
+		delay = b.Next(delay)
+		if delay < time.Second || delay > time.Minute || delay > 3*prev {
+			t.Errorf("delay %s is out of range after %s", delay, prev)
+			return
+		}
+	}
+}
+
+func TestBackoffMinDelay(t *testing.T) {
+	for _, b := range []BackoffPolicy{
+		ConstantBackoff(0),
+		ConstantBackoff(-time.Second),
+		ExponentialBackoff(0, 0, 2, 0),
+		ExponentialBackoff(-time.Second, time.Second, 0, 0),
+		ExponentialBackoff(time.Second, time.Second, 2, 1),
+		DecorrelatedJitterBackoff(0, 0),
+		DecorrelatedJitterBackoff(-time.Second, time.Second),
+	} {
+		var delay time.Duration
+		for i := 0; i < 100; i++ {
+			if delay = b.Next(delay); delay < minBackoffDelay {
+				t.Errorf("%T returned delay %s under %s", b, delay, minBackoffDelay)
+				return
+			}
+		}
+	}
+}
+
+func TestDefaultBackoffPolicy(t *testing.T) {
+	var delay time.Duration
+	for i := 0; i < 100; i++ {
+		if delay = DefaultBackoffPolicy.Next(0); delay < initialDelay*3/2 || delay > initialDelay*5/2 {
+			t.Errorf("first delay %s is out of range", delay)
+			return
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		delay = DefaultBackoffPolicy.Next(delay)
+	}
+
+	if delay < maxBackoff*(100-maxBackoffJitter)/100 || delay > maxBackoff {
+		t.Errorf("delay %s is out of the max backoff range", delay)
+	}
+}
@@ -0,0 +1,124 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	helpers "github.com/taubyte/p2p/helpers"
+)
+
+// DefaultBootstrapInterval is how often a BootstrapPolicy is checked when no
+// Interval is set.
+var DefaultBootstrapInterval = time.Minute
+
+// BootstrapPolicy sets how many bootstrap peers a node needs, and when to
+// bootstrap again.
+type BootstrapPolicy struct {
+	// MinPeers is the number of bootstrap peers that must be reached. Zero
+	// accepts a bootstrap that reached none.
+	MinPeers int
+	// Background keeps retrying in the background until MinPeers is reached,
+	// instead of failing the node creation.
+	Background bool
+	// Floor triggers a new bootstrap whenever the node is connected to fewer
+	// peers. Zero disables it.
+	Floor int
+	// Interval is how often Background and Floor are checked. Defaults to
+	// DefaultBootstrapInterval.
+	Interval time.Duration
+}
+
+// validate checks the policy against the bootstrap parameters of the node.
+// MinPeers can not be more than the bootstrap peers of a bootstrapping node.
+func (policy BootstrapPolicy) validate(bootstrap BootstrapParams) error {
+	if policy.MinPeers < 0 || policy.Floor < 0 || policy.Interval < 0 {
+		return errors.New("bootstrap policy values can not be negative")
+	}
+
+	if bootstrap.Enable && policy.MinPeers > len(bootstrap.Peers) {
+		return fmt.Errorf("bootstrap policy requires %d peers, only %d bootstrap peers are set", policy.MinPeers, len(bootstrap.Peers))
+	}
+
+	return nil
+}
+
+// bootstrap connects to peers, adds the reached ones to peering and keeps the
+// report.
+func (p *node) bootstrap(peers []peer.AddrInfo) (helpers.BootstrapReport, error) {
+	report, err := helpers.BootstrapPeers(p.ctx, p.host, p.dht, peers)
+
+	for _, f := range report.Failed {
+		logger.Warnf("bootstrap peer %s is unreachable: %s", f.Peer.ID, f.Err)
+	}
+
+	// TODO: get the peering service out of bootsrap
+	// bootstrap peers are not persisted, the list may change between runs
+	if ps, ok := p.peering.(*peeringService); ok {
+		ps.mu.Lock()
+		for _, n := range report.Connected {
+			ps.addPeer(n, false, nil)
+		}
+		ps.mu.Unlock()
+	}
+
+	p.bootstrapLock.Lock()
+	p.bootstrapReport = report
+	p.bootstrapLock.Unlock()
+
+	return report, err
+}
+
+// BootstrapReport returns the report of the last bootstrap of the node.
+func (p *node) BootstrapReport() helpers.BootstrapReport {
+	p.bootstrapLock.Lock()
+	defer p.bootstrapLock.Unlock()
+
+	return p.bootstrapReport
+}
+
+// bootstrapConnected counts the bootstrap peers the node is connected to.
+func (p *node) bootstrapConnected(peers []peer.AddrInfo) (count int) {
+	for _, pinfo := range peers {
+		if len(p.host.Network().ConnsToPeer(pinfo.ID)) > 0 {
+			count++
+		}
+	}
+
+	return
+}
+
+// maintainBootstrap bootstraps again whenever policy is not met, until the
+// node is closed.
+func (p *node) maintainBootstrap(peers []peer.AddrInfo, policy BootstrapPolicy) {
+	interval := policy.Interval
+	if interval == 0 {
+		interval = DefaultBootstrapInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var reason string
+		if policy.Background && p.bootstrapConnected(peers) < policy.MinPeers {
+			reason = fmt.Sprintf("less than %d bootstrap peers", policy.MinPeers)
+		} else if policy.Floor > 0 && len(p.host.Network().Peers()) < policy.Floor {
+			reason = fmt.Sprintf("less than %d peers", policy.Floor)
+		}
+
+		if reason != "" && !p.isClosed() {
+			logger.Infof("bootstrapping again, connected to %s", reason)
+			if _, err := p.bootstrap(peers); err != nil {
+				logger.Errorf("bootstrap failed with: %s", err)
+			}
+		}
+	}
+}
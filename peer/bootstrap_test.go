@@ -0,0 +1,131 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestBootstrapReport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Errorf("GenerateEd25519Key returned error `%s`", err.Error())
+		return
+	}
+	id, _ := peer.IDFromPrivateKey(key)
+
+	reachable := peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}
+	unreachable := peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/1")}}
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(Bootstrap(reachable, unreachable)))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	report := p2.BootstrapReport()
+	if len(report.Connected) != 1 || report.Connected[0].ID != p1.ID() {
+		t.Errorf("unexpected connected peers %v", report.Connected)
+	}
+
+	if len(report.Failed) != 1 || report.Failed[0].Peer.ID != id || report.Failed[0].Err == nil {
+		t.Errorf("unexpected failed peers %v", report.Failed)
+	}
+
+	if list := p2.Peering().ListPeers(); len(list) != 1 || list[0].ID != p1.ID() {
+		t.Errorf("unreachable bootstrap peer was added to peering: %v", list)
+	}
+
+	_, err = NewNode(
+		ctx,
+		WithListen("/ip4/127.0.0.1/tcp/0"),
+		WithBootstrap(Bootstrap(reachable, unreachable)),
+		WithBootstrapPolicy(BootstrapPolicy{MinPeers: 2}),
+	)
+	if err == nil {
+		t.Error("expected an error when less than MinPeers are reached")
+	}
+
+	p3, err := NewNode(
+		ctx,
+		WithListen("/ip4/127.0.0.1/tcp/0"),
+		WithBootstrap(Bootstrap(reachable, unreachable)),
+		WithBootstrapPolicy(BootstrapPolicy{MinPeers: 2, Background: true, Interval: 100 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p3.Close()
+
+	if _, err = NewNode(ctx, WithBootstrapPolicy(BootstrapPolicy{Floor: -1})); err == nil {
+		t.Error("expected an error for a negative floor")
+	}
+
+	if _, err = NewNode(ctx, WithBootstrapPolicy(BootstrapPolicy{MinPeers: -1})); err == nil {
+		t.Error("expected an error for negative min peers")
+	}
+
+	// whatever the order of the options
+	if _, err = NewNode(ctx, WithBootstrap(Bootstrap(reachable, unreachable)), WithBootstrapPolicy(BootstrapPolicy{MinPeers: 3})); err == nil {
+		t.Error("expected an error for more min peers than bootstrap peers")
+	}
+
+	if _, err = NewNode(ctx, WithBootstrapPolicy(BootstrapPolicy{MinPeers: 3}), WithBootstrap(Bootstrap(reachable, unreachable))); err == nil {
+		t.Error("expected an error for more min peers than bootstrap peers")
+	}
+}
+
+func TestBootstrapFloor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	p2, err := NewNode(
+		ctx,
+		WithListen("/ip4/127.0.0.1/tcp/0"),
+		WithBootstrap(Bootstrap(peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()})),
+		WithBootstrapPolicy(BootstrapPolicy{Floor: 1, Interval: 100 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	if err = p2.Peer().Network().ClosePeer(p1.ID()); err != nil {
+		t.Errorf("ClosePeer returned error `%s`", err.Error())
+		return
+	}
+
+	// peering would only reconnect after seconds
+	for len(p2.Peer().Network().ConnsToPeer(p1.ID())) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Error("node did not bootstrap again")
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
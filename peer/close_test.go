@@ -0,0 +1,81 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	peercore "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestCloseContext(t *testing.T) {
+	ctx := context.Background()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	release := make(chan struct{})
+	handling := make(chan struct{})
+	p1.SetStreamHandler("/test/close", func(s network.Stream) {
+		close(handling)
+		<-release
+		s.Close()
+	})
+
+	if _, err = p1.PubSubSubscribe("close", func(*pubsub.Message) {}, func(error) {}); err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	err = p2.Peer().Connect(ctx, peercore.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()})
+	if err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	s, err := p2.Peer().NewStream(ctx, p1.ID(), "/test/close")
+	if err != nil {
+		t.Errorf("NewStream returned error `%s`", err.Error())
+		return
+	}
+	defer s.Reset()
+	s.Write([]byte("hi"))
+
+	select {
+	case <-handling:
+	case <-time.After(5 * time.Second):
+		t.Error("stream handler was not called")
+		return
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	err = p1.CloseContext(cctx)
+	close(release)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "stream handlers") {
+		t.Errorf("expected stream handlers to time out, got `%v`", err)
+	}
+
+	if p1.CloseContext(ctx) != err {
+		t.Error("closing twice should return the same error")
+	}
+
+	if _, err = p1.AddFile(strings.NewReader("hello")); err != errorClosed {
+		t.Errorf("expected errorClosed, got `%v`", err)
+	}
+}
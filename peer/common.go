@@ -1,9 +1,16 @@
 package peer
 
-import logging "github.com/ipfs/go-log/v2"
+import (
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
 
 const UserAgent string = "Taubyte Node v1.0"
 
 var logger = logging.Logger("p2p.peer")
 
 var MaxBootstrapNodes = 5
+
+// CloseTimeout bounds how long Close waits for the node to shut down.
+var CloseTimeout = 10 * time.Second
@@ -0,0 +1,91 @@
+package peer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p-kad-dht/dual"
+	record "github.com/libp2p/go-libp2p-record"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	helpers "github.com/taubyte/p2p/helpers"
+)
+
+func TestDHTConfig(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		config    helpers.DHTConfig
+		dual      bool
+		protocols []protocol.ID
+	}{
+		{
+			config:    helpers.DHTConfig{Mode: helpers.DHTModeServer},
+			dual:      true,
+			protocols: []protocol.ID{"/ipfs/kad/1.0.0", "/ipfs/lan/kad/1.0.0"},
+		},
+		{
+			config:    helpers.DHTConfig{Mode: helpers.DHTModeServer, ProtocolPrefix: "/taubyte", BucketSize: 10},
+			dual:      true,
+			protocols: []protocol.ID{"/taubyte/kad/1.0.0", "/taubyte/lan/kad/1.0.0"},
+		},
+		{
+			config:    helpers.DHTConfig{Mode: helpers.DHTModeServer, ProtocolPrefix: "/taubyte", Topology: helpers.DHTWANOnly},
+			protocols: []protocol.ID{"/taubyte/kad/1.0.0"},
+		},
+		{
+			config:    helpers.DHTConfig{Mode: helpers.DHTModeServer, ProtocolPrefix: "/taubyte", Topology: helpers.DHTLANOnly},
+			protocols: []protocol.ID{"/taubyte/lan/kad/1.0.0"},
+		},
+		{
+			config: helpers.DHTConfig{Mode: helpers.DHTModeClient, ProtocolPrefix: "/taubyte"},
+			dual:   true,
+		},
+	} {
+		p, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithDHT(tc.config))
+		if err != nil {
+			t.Errorf("NewNode(%+v) returned error `%s`", tc.config, err.Error())
+			return
+		}
+
+		routing := p.(*node).dht
+		if _, ok := routing.(*dual.DHT); ok != tc.dual {
+			t.Errorf("expected a dual dht to be %v for %+v, got %T", tc.dual, tc.config, routing)
+		}
+		if _, ok := routing.(*dht.IpfsDHT); ok == tc.dual {
+			t.Errorf("expected a single dht to be %v for %+v, got %T", !tc.dual, tc.config, routing)
+		}
+
+		kad := make(map[protocol.ID]bool)
+		for _, pid := range p.Peer().Mux().Protocols() {
+			if strings.HasSuffix(string(pid), "/kad/1.0.0") {
+				kad[pid] = true
+			}
+		}
+
+		if len(kad) != len(tc.protocols) {
+			t.Errorf("expected dht protocols %v for %+v, got %v", tc.protocols, tc.config, kad)
+		}
+		for _, pid := range tc.protocols {
+			if !kad[pid] {
+				t.Errorf("expected dht protocols %v for %+v, got %v", tc.protocols, tc.config, kad)
+			}
+		}
+
+		p.Close()
+	}
+
+	for _, config := range []helpers.DHTConfig{
+		{BucketSize: 10},
+		{Validators: map[string]record.Validator{"test": record.PublicKeyValidator{}}},
+		{ProtocolPrefix: "/taubyte", Validators: map[string]record.Validator{"pk": record.PublicKeyValidator{}}},
+		{Mode: helpers.DHTMode(42)},
+		{Topology: helpers.DHTTopology(42)},
+		{Concurrency: -1},
+	} {
+		if _, err := NewNode(ctx, WithDHT(config)); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+}
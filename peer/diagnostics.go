@@ -0,0 +1,204 @@
+package peer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p-kad-dht/dual"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// DiagnosticsPingTimeout bounds the pings of the connected peers done while
+// building Diagnostics.
+var DiagnosticsPingTimeout = time.Second
+
+// Diagnostics is a snapshot of the health of a node. Addresses are strings
+// so it round trips through JSON.
+type Diagnostics struct {
+	ID    peer.ID
+	State string
+	Time  time.Time
+	// ListenAddrs are the addresses the node listens on, Addrs the ones it
+	// announces.
+	ListenAddrs  []string
+	Addrs        []string
+	Reachability string
+	Peers        []PeerDiagnostics
+	// Streams counts the open streams per protocol.
+	Streams map[protocol.ID]int
+	// RoutingTable is the number of peers in the DHT routing tables.
+	RoutingTable int
+	Topics       []TopicDiagnostics
+	// DatastoreSize is the disk usage of the datastore, zero if it does not
+	// report one.
+	DatastoreSize uint64
+	Peering       []PeeringDiagnostics
+}
+
+// PeerDiagnostics is a connected peer.
+type PeerDiagnostics struct {
+	ID    peer.ID
+	Addrs []string
+	// Latency is the moving average of the round trip times to the peer,
+	// pinged while building the snapshot. Zero if it did not answer within
+	// DiagnosticsPingTimeout and was never measured before.
+	Latency time.Duration
+	Conns   int
+	Streams int
+}
+
+// TopicDiagnostics is a joined pubsub topic and the peers in it.
+type TopicDiagnostics struct {
+	Name  string
+	Peers []peer.ID
+}
+
+// PeeringDiagnostics is a peer of the peering service.
+type PeeringDiagnostics struct {
+	ID        peer.ID
+	Addrs     []string
+	Connected bool
+	Attempts  int
+	NextRetry time.Time
+}
+
+// Diagnostics returns a snapshot of the health of the node. Connected peers
+// are pinged first, which takes up to DiagnosticsPingTimeout.
+func (p *node) Diagnostics() Diagnostics {
+	p.pingPeers(p.host.Network().Peers())
+
+	d := Diagnostics{
+		ID:           p.id,
+		State:        p.State().String(),
+		Time:         time.Now(),
+		Addrs:        addrStrings(p.host.Addrs()),
+		Reachability: p.Reachability().Reachability.String(),
+		Streams:      make(map[protocol.ID]int),
+		RoutingTable: routingTableSize(p.dht),
+	}
+
+	if addrs, err := p.host.Network().InterfaceListenAddresses(); err == nil {
+		d.ListenAddrs = addrStrings(addrs)
+	} else {
+		d.ListenAddrs = addrStrings(p.host.Network().ListenAddresses())
+	}
+
+	for _, pid := range p.host.Network().Peers() {
+		pd := PeerDiagnostics{ID: pid, Latency: p.host.Peerstore().LatencyEWMA(pid)}
+		for _, c := range p.host.Network().ConnsToPeer(pid) {
+			pd.Conns++
+			pd.Addrs = append(pd.Addrs, c.RemoteMultiaddr().String())
+			for _, s := range c.GetStreams() {
+				pd.Streams++
+				if proto := s.Protocol(); proto != "" {
+					d.Streams[proto]++
+				}
+			}
+		}
+
+		d.Peers = append(d.Peers, pd)
+	}
+	sort.Slice(d.Peers, func(i, j int) bool { return d.Peers[i].ID < d.Peers[j].ID })
+
+	if p.messaging != nil {
+		topics := p.messaging.GetTopics()
+		sort.Strings(topics)
+		for _, topic := range topics {
+			peers := p.messaging.ListPeers(topic)
+			sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+			d.Topics = append(d.Topics, TopicDiagnostics{Name: topic, Peers: peers})
+		}
+	}
+
+	if p.store != nil {
+		if size, err := datastore.DiskUsage(p.ctx, p.store); err == nil {
+			d.DatastoreSize = size
+		} else {
+			logger.Debugf("datastore disk usage failed with: %s", err)
+		}
+	}
+
+	if p.peering != nil {
+		for _, status := range p.peering.ListPeers() {
+			d.Peering = append(d.Peering, PeeringDiagnostics{
+				ID:        status.ID,
+				Addrs:     addrStrings(status.Addrs),
+				Connected: status.Connected,
+				Attempts:  status.Attempts,
+				NextRetry: status.NextRetry,
+			})
+		}
+	}
+
+	return d
+}
+
+// pingPeers pings peers once, concurrently, recording their latency in the
+// peerstore.
+func (p *node) pingPeers(peers []peer.ID) {
+	ctx, cancel := context.WithTimeout(p.ctx, DiagnosticsPingTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, pid := range peers {
+		wg.Add(1)
+		go func(pid peer.ID) {
+			defer wg.Done()
+
+			// canceling the context ends the ping
+			pctx, pcancel := context.WithCancel(ctx)
+			defer pcancel()
+
+			if res := <-ping.Ping(pctx, p.host, pid); res.Error != nil {
+				logger.Debugf("pinging %s failed with: %s", pid, res.Error)
+			}
+		}(pid)
+	}
+
+	wg.Wait()
+}
+
+// DiagnosticsHandler serves the diagnostics of node as JSON.
+func DiagnosticsHandler(node Node) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(node.Diagnostics()); err != nil {
+			logger.Errorf("encoding diagnostics failed with: %s", err)
+		}
+	})
+}
+
+func routingTableSize(r any) int {
+	switch d := r.(type) {
+	case *dht.IpfsDHT:
+		return d.RoutingTable().Size()
+	case *dual.DHT:
+		return d.WAN.RoutingTable().Size() + d.LAN.RoutingTable().Size()
+	}
+
+	return 0
+}
+
+func addrStrings(addrs []multiaddr.Multiaddr) []string {
+	strs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		strs = append(strs, addr.String())
+	}
+
+	return strs
+}
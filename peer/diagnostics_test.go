@@ -0,0 +1,116 @@
+package peer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDiagnostics(t *testing.T) {
+	ctx := context.Background()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	p1.SetStreamHandler("/diagnostics/1.0", func(s network.Stream) {
+		<-p1.Done()
+		s.Reset()
+	})
+
+	for _, p := range []Node{p1, p2} {
+		if _, err = p.PubSubSubscribe("diagnostics", func(*pubsub.Message) {}, func(error) {}); err != nil {
+			t.Errorf("PubSubSubscribe returned error `%s`", err.Error())
+			return
+		}
+	}
+
+	p2.Peering().AddPeer(peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()})
+	if err = p2.Peer().Connect(ctx, peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	s, err := p2.Peer().NewStream(ctx, p1.ID(), "/diagnostics/1.0")
+	if err != nil {
+		t.Errorf("NewStream returned error `%s`", err.Error())
+		return
+	}
+	defer s.Reset()
+
+	var d Diagnostics
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(100 * time.Millisecond) {
+		if d = p2.Diagnostics(); len(d.Topics) == 1 && len(d.Topics[0].Peers) == 1 {
+			break
+		}
+	}
+
+	if d.ID != p2.ID() || d.State != StateRunning.String() || len(d.ListenAddrs) == 0 || len(d.Addrs) == 0 {
+		t.Errorf("unexpected node diagnostics %+v", d)
+	}
+
+	if len(d.Peers) != 1 || d.Peers[0].ID != p1.ID() || d.Peers[0].Conns == 0 || d.Peers[0].Streams == 0 {
+		t.Errorf("unexpected peers %+v", d.Peers)
+	}
+
+	// peers are pinged while building the snapshot
+	if len(d.Peers) == 1 && d.Peers[0].Latency <= 0 {
+		t.Errorf("expected the latency of %s, got %s", p1.ID(), d.Peers[0].Latency)
+	}
+
+	// the default pebble datastore reports its disk usage
+	if d.DatastoreSize == 0 {
+		t.Error("expected the datastore size")
+	}
+
+	if d.Streams["/diagnostics/1.0"] != 1 {
+		t.Errorf("expected an open /diagnostics/1.0 stream, got %v", d.Streams)
+	}
+
+	if len(d.Topics) != 1 || d.Topics[0].Name != "diagnostics" || len(d.Topics[0].Peers) != 1 || d.Topics[0].Peers[0] != p1.ID() {
+		t.Errorf("unexpected topics %+v", d.Topics)
+	}
+
+	if len(d.Peering) != 1 || d.Peering[0].ID != p1.ID() || len(d.Peering[0].Addrs) == 0 {
+		t.Errorf("unexpected peering %+v", d.Peering)
+	}
+
+	w := httptest.NewRecorder()
+	DiagnosticsHandler(p2).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/diagnostics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type `%s`", ct)
+	}
+
+	var served Diagnostics
+	if err = json.NewDecoder(w.Body).Decode(&served); err != nil {
+		t.Errorf("decoding diagnostics returned error `%s`", err.Error())
+		return
+	}
+
+	if served.ID != p2.ID() || len(served.Peers) != 1 || served.Peers[0].ID != p1.ID() {
+		t.Errorf("unexpected served diagnostics %+v", served)
+	}
+
+	w = httptest.NewRecorder()
+	DiagnosticsHandler(p2).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/diagnostics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for a POST, got %d", w.Code)
+	}
+}
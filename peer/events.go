@@ -0,0 +1,262 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// State is the lifecycle state of a node.
+type State int32
+
+const (
+	StateStarting State = iota
+	StateRunning
+	StateClosing
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateClosing:
+		return "closing"
+	case StateClosed:
+		return "closed"
+	}
+
+	return fmt.Sprintf("state(%d)", int32(s))
+}
+
+// EventType identifies the kind of an Event.
+type EventType int
+
+const (
+	// EventStateChanged is emitted on lifecycle transitions. State is set.
+	EventStateChanged EventType = iota
+	// EventPeerConnected is emitted when a first connection to Peer is opened.
+	EventPeerConnected
+	// EventPeerDisconnected is emitted when the last connection to Peer is closed.
+	EventPeerDisconnected
+	// EventReachabilityChanged is emitted when the detected reachability
+	// changes. Reachability is set.
+	EventReachabilityChanged
+	// EventPeeringConnected is emitted by the peering service when a peer it
+	// maintains connects.
+	EventPeeringConnected
+	// EventPeeringDisconnected is emitted by the peering service when a peer
+	// it maintains disconnects.
+	EventPeeringDisconnected
+	// EventAddressesChanged is emitted when the addresses of the node change.
+	// Addrs is set.
+	EventAddressesChanged
+	// EventRelaysChanged is emitted when the relay reservations of the node
+	// change. Addrs is set to the relayed addresses.
+	EventRelaysChanged
+	// EventHolePunchSucceeded is emitted when a hole punch with Peer succeeds.
+	EventHolePunchSucceeded
+	// EventHolePunchFailed is emitted when a hole punch with Peer fails.
+	EventHolePunchFailed
+	// EventConfirmedAddrsChanged is emitted when AutoNAT v2 confirms or
+	// revokes addresses of the node. Addrs is set to the confirmed ones.
+	EventConfirmedAddrsChanged
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventStateChanged:
+		return "state-changed"
+	case EventPeerConnected:
+		return "peer-connected"
+	case EventPeerDisconnected:
+		return "peer-disconnected"
+	case EventReachabilityChanged:
+		return "reachability-changed"
+	case EventPeeringConnected:
+		return "peering-connected"
+	case EventPeeringDisconnected:
+		return "peering-disconnected"
+	case EventAddressesChanged:
+		return "addresses-changed"
+	case EventRelaysChanged:
+		return "relays-changed"
+	case EventHolePunchSucceeded:
+		return "hole-punch-succeeded"
+	case EventHolePunchFailed:
+		return "hole-punch-failed"
+	case EventConfirmedAddrsChanged:
+		return "confirmed-addresses-changed"
+	}
+
+	return fmt.Sprintf("event(%d)", int(t))
+}
+
+// Event is a node event. Only the fields relevant to Type are set.
+type Event struct {
+	Type         EventType
+	State        State
+	Peer         peer.ID
+	Reachability network.Reachability
+	Addrs        []multiaddr.Multiaddr
+}
+
+// EventsBufferSize is the buffer size of channels returned by Events. Events
+// are dropped for subscribers that do not keep up.
+var EventsBufferSize = 64
+
+type eventHub struct {
+	lock   sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+	done   chan struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subs: make(map[chan Event]struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+func (h *eventHub) subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, EventsBufferSize)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch
+	}
+
+	h.subs[ch] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.unsubscribe(ch)
+		case <-h.done:
+		}
+	}()
+
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+func (h *eventHub) emit(e Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			logger.Warnf("dropping %s event, subscriber is too slow", e.Type)
+		}
+	}
+}
+
+func (h *eventHub) close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.closed = true
+	close(h.done)
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// State returns the lifecycle state of the node.
+func (p *node) State() State {
+	return State(p.state.Load())
+}
+
+func (p *node) setState(s State) {
+	if State(p.state.Swap(int32(s))) != s {
+		p.events.emit(Event{Type: EventStateChanged, State: s})
+	}
+}
+
+func (p *node) isClosed() bool {
+	return p.State() >= StateClosing
+}
+
+// Events returns a channel of node events. It is closed when ctx is done or
+// once the node is closed.
+func (p *node) Events(ctx context.Context) <-chan Event {
+	return p.events.subscribe(ctx)
+}
+
+// watchHostEvents forwards host connectivity, reachability and address events
+// until the host event bus or the subscription is closed.
+func (p *node) watchHostEvents() error {
+	sub, err := p.host.EventBus().Subscribe([]interface{}{
+		(*event.EvtPeerConnectednessChanged)(nil),
+		(*event.EvtLocalReachabilityChanged)(nil),
+		(*event.EvtLocalAddressesUpdated)(nil),
+		(*event.EvtHostReachableAddrsChanged)(nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	p.hostEvents = sub
+	go func() {
+		for e := range sub.Out() {
+			switch evt := e.(type) {
+			case event.EvtPeerConnectednessChanged:
+				switch evt.Connectedness {
+				case network.Connected:
+					p.events.emit(Event{Type: EventPeerConnected, Peer: evt.Peer})
+				case network.NotConnected:
+					p.events.emit(Event{Type: EventPeerDisconnected, Peer: evt.Peer})
+				}
+			case event.EvtLocalReachabilityChanged:
+				p.setReachability(evt.Reachability)
+				p.events.emit(Event{Type: EventReachabilityChanged, Reachability: evt.Reachability})
+			case event.EvtLocalAddressesUpdated:
+				addrs := make([]multiaddr.Multiaddr, 0, len(evt.Current))
+				for _, a := range evt.Current {
+					addrs = append(addrs, a.Address)
+				}
+				p.events.emit(Event{Type: EventAddressesChanged, Addrs: addrs})
+
+				if relays, changed := p.updateRelays(addrs); changed {
+					relayed := make([]multiaddr.Multiaddr, 0, len(relays))
+					for _, r := range relays {
+						relayed = append(relayed, r.Addr)
+					}
+					p.events.emit(Event{Type: EventRelaysChanged, Addrs: relayed})
+				}
+			case event.EvtHostReachableAddrsChanged:
+				p.setConfirmedAddrs(evt.Reachable)
+				p.events.emit(Event{Type: EventConfirmedAddrsChanged, Addrs: evt.Reachable})
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	peercore "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNodeEvents(t *testing.T) {
+	ctx := context.Background()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	if p1.State() != StateRunning {
+		t.Errorf("expected node to be running, got %s", p1.State())
+	}
+
+	events := p1.Events(ctx)
+
+	err = p2.Peer().Connect(ctx, peercore.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()})
+	if err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	var connected bool
+	var states []State
+	timeout := time.After(10 * time.Second)
+	for done := false; !done; {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			switch e.Type {
+			case EventPeerConnected:
+				if e.Peer == p2.ID() && !connected {
+					connected = true
+					go p1.Close()
+				}
+			case EventStateChanged:
+				states = append(states, e.State)
+			}
+		case <-timeout:
+			t.Error("events channel was not closed")
+			return
+		}
+	}
+
+	if !connected {
+		t.Error("missing peer connected event")
+	}
+
+	if len(states) != 2 || states[0] != StateClosing || states[1] != StateClosed {
+		t.Errorf("unexpected state transitions %v", states)
+	}
+
+	if p1.State() != StateClosed {
+		t.Errorf("expected node to be closed, got %s", p1.State())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
 	cid "github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -16,8 +17,16 @@ type ReadSeekCloser interface {
 
 var errorClosed = errors.New("node is closed")
 
-func (p *node) DeleteFile(id string) error {
-	if !p.closed {
+// measureFile reports a file operation started at start. Use it deferred,
+// with a pointer to the named error of the operation.
+func (p *node) measureFile(op string, start time.Time, err *error) {
+	p.Metrics().FileOp(op, time.Since(start), *err)
+}
+
+func (p *node) DeleteFile(id string) (err error) {
+	defer p.measureFile("delete", time.Now(), &err)
+
+	if !p.isClosed() {
 		_cid, err := cid.Decode(id)
 		if err != nil {
 			return err
@@ -30,7 +39,9 @@ func (p *node) DeleteFile(id string) error {
 }
 
 func (p *node) AddFile(r io.Reader) (_cid string, err error) {
-	if !p.closed {
+	defer p.measureFile("add", time.Now(), &err)
+
+	if !p.isClosed() {
 		var n ipld.Node
 		n, err = p.ipfs.AddFile(p.ctx, r, nil)
 		if err == nil {
@@ -44,8 +55,10 @@ func (p *node) AddFile(r io.Reader) (_cid string, err error) {
 }
 
 // Note: context should have a timeout and depend on the peer context as parent
-func (p *node) GetFile(ctx context.Context, id string) (ReadSeekCloser, error) {
-	if !p.closed {
+func (p *node) GetFile(ctx context.Context, id string) (_ ReadSeekCloser, err error) {
+	defer p.measureFile("get", time.Now(), &err)
+
+	if !p.isClosed() {
 		_cid, err := cid.Decode(id)
 		if err != nil {
 			return nil, err
@@ -56,16 +69,20 @@ func (p *node) GetFile(ctx context.Context, id string) (ReadSeekCloser, error) {
 	return nil, errorClosed
 }
 
-func (p *node) GetFileFromCid(ctx context.Context, cid cid.Cid) (ReadSeekCloser, error) {
-	if !p.closed {
+func (p *node) GetFileFromCid(ctx context.Context, cid cid.Cid) (_ ReadSeekCloser, err error) {
+	defer p.measureFile("get", time.Now(), &err)
+
+	if !p.isClosed() {
 		return p.ipfs.GetFile(ctx, cid)
 	}
 
 	return nil, errorClosed
 }
 
-func (p *node) AddFileForCid(r io.Reader) (cid.Cid, error) {
-	if !p.closed {
+func (p *node) AddFileForCid(r io.Reader) (_ cid.Cid, err error) {
+	defer p.measureFile("add", time.Now(), &err)
+
+	if !p.isClosed() {
 		n, err := p.ipfs.AddFile(p.ctx, r, nil)
 		if err != nil {
 			return cid.Cid{}, err
@@ -0,0 +1,437 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// bansPrefix is where bans are stored in the node datastore.
+var bansPrefix = datastore.NewKey("/gater/bans")
+
+const bootstrapTag = "bootstrap"
+
+// GaterConfig sets the static rules of the connection gater. Subnets are in
+// CIDR notation, like "10.0.0.0/8".
+type GaterConfig struct {
+	// AllowPeers and AllowSubnets, when any is set, restrict connections to
+	// the peers listed or connecting from the subnets listed.
+	AllowPeers   []peer.ID
+	AllowSubnets []string
+	// DenyPeers and DenySubnets block peers, and peers connecting from the
+	// subnets listed.
+	DenyPeers   []peer.ID
+	DenySubnets []string
+}
+
+// WithGater sets the static rules of the connection gater of the node.
+func WithGater(config GaterConfig) Option {
+	return func(o *options) error {
+		if _, err := parseSubnets(config.AllowSubnets); err != nil {
+			return err
+		}
+
+		if _, err := parseSubnets(config.DenySubnets); err != nil {
+			return err
+		}
+
+		o.gater = config
+		return nil
+	}
+}
+
+func parseSubnets(subnets []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(subnets))
+	for _, s := range subnets {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing subnet `%s` failed with: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// PeerBan is a ban of a peer. A zero Until bans the peer until unbanned.
+type PeerBan struct {
+	Peer  peer.ID
+	Until time.Time
+}
+
+type banEntry struct {
+	Until int64
+}
+
+// Gater decides which connections a node accepts and dials. Protected peers,
+// like peering and bootstrap peers, are always allowed. Otherwise banned and
+// denied peers are blocked, then the allow lists, if any, apply.
+type Gater struct {
+	ctx   context.Context
+	store datastore.Datastore
+	now   func() time.Time
+
+	allowPeers   map[peer.ID]struct{}
+	allowSubnets []*net.IPNet
+	denyPeers    map[peer.ID]struct{}
+	denySubnets  []*net.IPNet
+
+	lock      sync.RWMutex
+	host      host.Host
+	bans      map[peer.ID]time.Time
+	protected map[peer.ID]map[string]struct{}
+}
+
+var _ connmgr.ConnectionGater = (*Gater)(nil)
+
+func newGater(ctx context.Context, store datastore.Datastore, config GaterConfig) (*Gater, error) {
+	g := &Gater{
+		ctx:        ctx,
+		store:      store,
+		now:        time.Now,
+		allowPeers: make(map[peer.ID]struct{}, len(config.AllowPeers)),
+		denyPeers:  make(map[peer.ID]struct{}, len(config.DenyPeers)),
+		bans:       make(map[peer.ID]time.Time),
+		protected:  make(map[peer.ID]map[string]struct{}),
+	}
+
+	var err error
+	if g.allowSubnets, err = parseSubnets(config.AllowSubnets); err != nil {
+		return nil, err
+	}
+
+	if g.denySubnets, err = parseSubnets(config.DenySubnets); err != nil {
+		return nil, err
+	}
+
+	for _, id := range config.AllowPeers {
+		g.allowPeers[id] = struct{}{}
+	}
+
+	for _, id := range config.DenyPeers {
+		g.denyPeers[id] = struct{}{}
+	}
+
+	if err = g.load(); err != nil {
+		return nil, fmt.Errorf("loading bans failed with: %w", err)
+	}
+
+	return g, nil
+}
+
+// load restores the bans persisted in the datastore, dropping expired ones.
+func (g *Gater) load() error {
+	if g.store == nil {
+		return nil
+	}
+
+	results, err := g.store.Query(g.ctx, query.Query{Prefix: bansPrefix.String()})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	now := g.now()
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+
+		key := datastore.RawKey(r.Key)
+		id, err := peer.Decode(key.BaseNamespace())
+		if err != nil {
+			logger.Warnf("ignoring ban `%s`: %s", r.Key, err)
+			continue
+		}
+
+		var entry banEntry
+		if err = cbor.Unmarshal(r.Value, &entry); err != nil {
+			logger.Warnf("ignoring ban `%s`: %s", r.Key, err)
+			continue
+		}
+
+		var until time.Time
+		if entry.Until != 0 {
+			until = time.Unix(0, entry.Until)
+			if !until.After(now) {
+				if err = g.store.Delete(g.ctx, key); err != nil {
+					logger.Errorf("removing expired ban of %s failed with: %s", id, err)
+				}
+				continue
+			}
+		}
+
+		g.bans[id] = until
+	}
+
+	return nil
+}
+
+func (g *Gater) setHost(h host.Host) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.host = h
+}
+
+// Ban blocks id for duration, or until unbanned if duration is not positive,
+// and closes the connections to it. Protected peers can not be banned.
+func (g *Gater) Ban(id peer.ID, duration time.Duration) error {
+	g.lock.Lock()
+	if _, ok := g.protected[id]; ok {
+		g.lock.Unlock()
+		return fmt.Errorf("peer %s is protected", id)
+	}
+
+	var (
+		until time.Time
+		entry banEntry
+	)
+	if duration > 0 {
+		until = g.now().Add(duration)
+		entry.Until = until.UnixNano()
+	}
+	g.bans[id] = until
+	h := g.host
+	g.lock.Unlock()
+
+	if h != nil {
+		if err := h.Network().ClosePeer(id); err != nil {
+			logger.Warnf("closing connections to banned peer %s failed with: %s", id, err)
+		}
+	}
+
+	if g.store == nil {
+		return nil
+	}
+
+	data, err := cbor.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err = g.store.Put(g.ctx, bansPrefix.ChildString(id.String()), data); err != nil {
+		return fmt.Errorf("persisting ban of %s failed with: %w", id, err)
+	}
+
+	return nil
+}
+
+// Unban lifts the ban of id, if any.
+func (g *Gater) Unban(id peer.ID) error {
+	g.lock.Lock()
+	delete(g.bans, id)
+	g.lock.Unlock()
+
+	if g.store == nil {
+		return nil
+	}
+
+	if err := g.store.Delete(g.ctx, bansPrefix.ChildString(id.String())); err != nil {
+		return fmt.Errorf("removing ban of %s failed with: %w", id, err)
+	}
+
+	return nil
+}
+
+// dropExpiredBan removes the ban of id expired at until, and its datastore
+// key, unless id was banned again meanwhile.
+func (g *Gater) dropExpiredBan(id peer.ID, until time.Time) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if current, ok := g.bans[id]; !ok || !current.Equal(until) {
+		return
+	}
+
+	delete(g.bans, id)
+	if g.store == nil {
+		return
+	}
+
+	if err := g.store.Delete(g.ctx, bansPrefix.ChildString(id.String())); err != nil {
+		logger.Errorf("removing expired ban of %s failed with: %s", id, err)
+	}
+}
+
+// Bans returns the bans in effect, sorted by peer.
+func (g *Gater) Bans() []PeerBan {
+	var expired []PeerBan
+
+	g.lock.RLock()
+	now := g.now()
+	bans := make([]PeerBan, 0, len(g.bans))
+	for id, until := range g.bans {
+		if until.IsZero() || until.After(now) {
+			bans = append(bans, PeerBan{Peer: id, Until: until})
+		} else {
+			expired = append(expired, PeerBan{Peer: id, Until: until})
+		}
+	}
+	g.lock.RUnlock()
+
+	for _, ban := range expired {
+		g.dropExpiredBan(ban.Peer, ban.Until)
+	}
+
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Peer < bans[j].Peer })
+
+	return bans
+}
+
+// Protect makes id always allowed, until unprotected for every tag.
+func (g *Gater) Protect(id peer.ID, tag string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	tags, ok := g.protected[id]
+	if !ok {
+		tags = make(map[string]struct{})
+		g.protected[id] = tags
+	}
+	tags[tag] = struct{}{}
+}
+
+// Unprotect removes the protection of id for tag. It reports whether id is
+// still protected by another tag.
+func (g *Gater) Unprotect(id peer.ID, tag string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	tags, ok := g.protected[id]
+	if !ok {
+		return false
+	}
+
+	delete(tags, tag)
+	if len(tags) == 0 {
+		delete(g.protected, id)
+		return false
+	}
+
+	return true
+}
+
+// IsProtected reports whether id is protected.
+func (g *Gater) IsProtected(id peer.ID) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	_, ok := g.protected[id]
+	return ok
+}
+
+// peerVerdict decides on id alone. It is final unless decided is false, in
+// which case the address of the peer is needed.
+func (g *Gater) peerVerdict(id peer.ID) (allow, decided bool) {
+	g.lock.RLock()
+	_, protected := g.protected[id]
+	until, banned := g.bans[id]
+	g.lock.RUnlock()
+
+	if protected {
+		return true, true
+	}
+
+	if banned {
+		if until.IsZero() || until.After(g.now()) {
+			return false, true
+		}
+		g.dropExpiredBan(id, until)
+	}
+
+	if _, ok := g.denyPeers[id]; ok {
+		return false, true
+	}
+
+	if _, ok := g.allowPeers[id]; ok && len(g.denySubnets) == 0 {
+		return true, true
+	}
+
+	if len(g.denySubnets) > 0 || len(g.allowSubnets) > 0 {
+		return false, false
+	}
+
+	return len(g.allowPeers) == 0, true
+}
+
+// allowed decides on id connecting from, or dialed at, addr.
+func (g *Gater) allowed(id peer.ID, addr multiaddr.Multiaddr) bool {
+	if allow, decided := g.peerVerdict(id); decided {
+		return allow
+	}
+
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		// addresses without IP, like relayed ones, only match peer rules
+		ip = nil
+	}
+
+	if ip != nil {
+		for _, n := range g.denySubnets {
+			if n.Contains(ip) {
+				return false
+			}
+		}
+	}
+
+	if len(g.allowPeers) == 0 && len(g.allowSubnets) == 0 {
+		return true
+	}
+
+	if _, ok := g.allowPeers[id]; ok {
+		return true
+	}
+
+	if ip != nil {
+		for _, n := range g.allowSubnets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (g *Gater) InterceptPeerDial(id peer.ID) bool {
+	allow, decided := g.peerVerdict(id)
+	return allow || !decided
+}
+
+func (g *Gater) InterceptAddrDial(id peer.ID, addr multiaddr.Multiaddr) bool {
+	return g.allowed(id, addr)
+}
+
+// InterceptAccept allows every connection, the peer is not known yet and
+// protected peers must not be blocked. See InterceptSecured.
+func (g *Gater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *Gater) InterceptSecured(_ network.Direction, id peer.ID, conn network.ConnMultiaddrs) bool {
+	return g.allowed(id, conn.RemoteMultiaddr())
+}
+
+func (g *Gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// Gater returns the connection gater of the node.
+func (p *node) Gater() *Gater {
+	return p.gater
+}
@@ -0,0 +1,202 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/taubyte/p2p/datastores/mem"
+	keypair "github.com/taubyte/p2p/keypair"
+)
+
+func TestGaterBan(t *testing.T) {
+	ctx := context.Background()
+
+	store := mem.New()
+	key := keypair.NewRaw()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithDatastore(store), WithPrivateKey(key))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	info1 := peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}
+	if err = p2.Peer().Connect(ctx, info1); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	if err = p1.Gater().Ban(p2.ID(), 0); err != nil {
+		t.Errorf("Ban returned error `%s`", err.Error())
+		return
+	}
+
+	if len(p1.Peer().Network().ConnsToPeer(p2.ID())) != 0 {
+		t.Error("connections to a banned peer were not closed")
+	}
+
+	// the dialer may see the connection before it is rejected
+	p2.Peer().Network().ClosePeer(p1.ID())
+	p2.Peer().Connect(ctx, info1)
+	if len(p1.Peer().Network().ConnsToPeer(p2.ID())) != 0 {
+		t.Error("banned peer connected")
+	}
+
+	if err = p1.Peer().Connect(ctx, peer.AddrInfo{ID: p2.ID(), Addrs: p2.Peer().Addrs()}); err == nil {
+		t.Error("connected to a banned peer")
+	}
+
+	p1.Close()
+
+	// bans survive restarts
+	p1, err = NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithDatastore(store), WithPrivateKey(key))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	bans := p1.Gater().Bans()
+	if len(bans) != 1 || bans[0].Peer != p2.ID() || !bans[0].Until.IsZero() {
+		t.Errorf("unexpected bans %v", bans)
+		return
+	}
+
+	if err = p1.Gater().Unban(p2.ID()); err != nil {
+		t.Errorf("Unban returned error `%s`", err.Error())
+		return
+	}
+
+	p2.Peer().Network().ClosePeer(p1.ID())
+	if err = p2.Peer().Connect(ctx, peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect after Unban returned error `%s`", err.Error())
+	}
+}
+
+func TestGaterBanExpires(t *testing.T) {
+	ctx := context.Background()
+	store := mem.New()
+	g, err := newGater(ctx, store, GaterConfig{})
+	if err != nil {
+		t.Errorf("newGater returned error `%s`", err.Error())
+		return
+	}
+
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	_, pub, _ := crypto.GenerateEd25519Key(nil)
+	id, _ := peer.IDFromPublicKey(pub)
+	if err = g.Ban(id, time.Minute); err != nil {
+		t.Errorf("Ban returned error `%s`", err.Error())
+		return
+	}
+
+	if g.InterceptPeerDial(id) {
+		t.Error("banned peer is allowed")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if !g.InterceptPeerDial(id) {
+		t.Error("peer is still banned after the ban expired")
+	}
+
+	if has, _ := store.Has(ctx, bansPrefix.ChildString(id.String())); has {
+		t.Error("expired ban is still persisted")
+	}
+
+	_, pub, _ = crypto.GenerateEd25519Key(nil)
+	other, _ := peer.IDFromPublicKey(pub)
+	if err = g.Ban(other, time.Minute); err != nil {
+		t.Errorf("Ban returned error `%s`", err.Error())
+		return
+	}
+
+	now = now.Add(2 * time.Minute)
+	if bans := g.Bans(); len(bans) != 0 {
+		t.Errorf("expired ban is listed: %v", bans)
+	}
+
+	if has, _ := store.Has(ctx, bansPrefix.ChildString(other.String())); has {
+		t.Error("expired ban is still persisted")
+	}
+
+	g.lock.RLock()
+	if len(g.bans) != 0 {
+		t.Errorf("expired bans are kept: %v", g.bans)
+	}
+	g.lock.RUnlock()
+
+	g.Protect(id, "test")
+	if err = g.Ban(id, 0); err == nil {
+		t.Error("expected an error banning a protected peer")
+	}
+}
+
+func TestGaterRules(t *testing.T) {
+	ctx := context.Background()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithGater(GaterConfig{
+		DenySubnets: []string{"127.0.0.0/8"},
+	}))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	info1 := peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}
+	p2.Peer().Connect(ctx, info1)
+	if len(p1.Peer().Network().ConnsToPeer(p2.ID())) != 0 {
+		t.Error("peer from a denied subnet connected")
+	}
+
+	// peering peers are never blocked
+	p1.Peering().AddPeer(peer.AddrInfo{ID: p2.ID(), Addrs: p2.Peer().Addrs()})
+	defer p1.Peering().RemovePeer(p2.ID())
+
+	p2.Peer().Network().ClosePeer(p1.ID())
+	if err = p2.Peer().Connect(ctx, info1); err != nil {
+		t.Errorf("peering peer was blocked: %s", err.Error())
+	}
+
+	p3, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithGater(GaterConfig{
+		AllowPeers: []peer.ID{p2.ID()},
+	}))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p3.Close()
+
+	info3 := peer.AddrInfo{ID: p3.ID(), Addrs: p3.Peer().Addrs()}
+	p1.Peer().Connect(ctx, info3)
+	if len(p3.Peer().Network().ConnsToPeer(p1.ID())) != 0 {
+		t.Error("peer not in the allow list connected")
+	}
+
+	if err = p2.Peer().Connect(ctx, info3); err != nil {
+		t.Errorf("allowed peer was blocked: %s", err.Error())
+	}
+
+	if _, err = NewNode(ctx, WithGater(GaterConfig{AllowSubnets: []string{"not a subnet"}})); err == nil {
+		t.Error("expected an error for an invalid subnet")
+	}
+}
@@ -0,0 +1,143 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// errStoreClosed is returned by the ipfs-lite datastore once the node closed it.
+var errStoreClosed = errors.New("datastore is closed")
+
+// ipfsStore is the datastore given to ipfs-lite. ipfs-lite flushes its
+// reprovider queue in the background once its context is cancelled, which
+// can race with the node closing the datastore. Operations fail once the node
+// closed it instead of reaching the closed datastore.
+type ipfsStore struct {
+	datastore.Batching
+
+	lock   sync.RWMutex
+	closed bool
+}
+
+var _ datastore.Batching = (*ipfsStore)(nil)
+
+func newIPFSStore(store datastore.Batching) *ipfsStore {
+	return &ipfsStore{Batching: store}
+}
+
+// close waits for in-flight operations and fails the next ones. It does not
+// close the underlying datastore.
+func (s *ipfsStore) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+}
+
+func (s *ipfsStore) Get(ctx context.Context, key datastore.Key) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return nil, errStoreClosed
+	}
+
+	return s.Batching.Get(ctx, key)
+}
+
+func (s *ipfsStore) Has(ctx context.Context, key datastore.Key) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return false, errStoreClosed
+	}
+
+	return s.Batching.Has(ctx, key)
+}
+
+func (s *ipfsStore) GetSize(ctx context.Context, key datastore.Key) (int, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return -1, errStoreClosed
+	}
+
+	return s.Batching.GetSize(ctx, key)
+}
+
+func (s *ipfsStore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return nil, errStoreClosed
+	}
+
+	return s.Batching.Query(ctx, q)
+}
+
+func (s *ipfsStore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return errStoreClosed
+	}
+
+	return s.Batching.Put(ctx, key, value)
+}
+
+func (s *ipfsStore) Delete(ctx context.Context, key datastore.Key) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return errStoreClosed
+	}
+
+	return s.Batching.Delete(ctx, key)
+}
+
+func (s *ipfsStore) Sync(ctx context.Context, prefix datastore.Key) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return errStoreClosed
+	}
+
+	return s.Batching.Sync(ctx, prefix)
+}
+
+// Close is a no-op, the node owns the datastore.
+func (s *ipfsStore) Close() error {
+	return nil
+}
+
+func (s *ipfsStore) Batch(ctx context.Context) (datastore.Batch, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return nil, errStoreClosed
+	}
+
+	b, err := s.Batching.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ipfsBatch{Batch: b, store: s}, nil
+}
+
+type ipfsBatch struct {
+	datastore.Batch
+	store *ipfsStore
+}
+
+func (b *ipfsBatch) Commit(ctx context.Context) error {
+	b.store.lock.RLock()
+	defer b.store.lock.RUnlock()
+	if b.store.closed {
+		return errStoreClosed
+	}
+
+	return b.Batch.Commit(ctx)
+}
@@ -0,0 +1,250 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var (
+	// DefaultKeepAliveInterval is how often a keepalive publishes on its topic.
+	DefaultKeepAliveInterval = 20 * time.Second
+	// DefaultKeepAliveWeight is the connection manager tag weight of a peer
+	// that was just heard from.
+	DefaultKeepAliveWeight = 100
+)
+
+type keepAliveOptions struct {
+	interval time.Duration
+	ttl      time.Duration
+	weight   int
+}
+
+// KeepAliveOption configures a KeepAlive.
+type KeepAliveOption func(o *keepAliveOptions) error
+
+// KeepAliveInterval sets how often the node publishes on the keepalive topic
+// and refreshes the tags. Defaults to DefaultKeepAliveInterval.
+func KeepAliveInterval(interval time.Duration) KeepAliveOption {
+	return func(o *keepAliveOptions) error {
+		if interval <= 0 {
+			return errors.New("interval must be positive")
+		}
+
+		o.interval = interval
+		return nil
+	}
+}
+
+// KeepAliveTTL sets how long a peer stays tagged after its last message. The
+// tag weight decays linearly over that period. Defaults to three intervals.
+func KeepAliveTTL(ttl time.Duration) KeepAliveOption {
+	return func(o *keepAliveOptions) error {
+		if ttl <= 0 {
+			return errors.New("ttl must be positive")
+		}
+
+		o.ttl = ttl
+		return nil
+	}
+}
+
+// KeepAliveWeight sets the tag weight of a peer that was just heard from.
+// Defaults to DefaultKeepAliveWeight.
+func KeepAliveWeight(weight int) KeepAliveOption {
+	return func(o *keepAliveOptions) error {
+		if weight <= 0 {
+			return errors.New("weight must be positive")
+		}
+
+		o.weight = weight
+		return nil
+	}
+}
+
+// KeepAlive keeps connections to the peers publishing on a topic. Every peer
+// a message is received from is tagged in the connection manager, under a tag
+// specific to the topic, until it has been silent for the TTL.
+type KeepAlive struct {
+	node    *node
+	name    string
+	tag     string
+	opts    keepAliveOptions
+	subs    *Subscription
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+
+	lock  sync.Mutex
+	peers map[peer.ID]time.Time
+
+	now func() time.Time
+}
+
+// KeepAlive publishes on topic name every interval and keeps connections to
+// the peers doing the same, until ctx is done or Close is called.
+func (p *node) KeepAlive(ctx context.Context, name string, opts ...KeepAliveOption) (*KeepAlive, error) {
+	if p.isClosed() {
+		return nil, errorClosed
+	}
+
+	o := keepAliveOptions{
+		interval: DefaultKeepAliveInterval,
+		weight:   DefaultKeepAliveWeight,
+	}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.ttl == 0 {
+		o.ttl = 3 * o.interval
+	}
+
+	k := &KeepAlive{
+		node:    p,
+		name:    name,
+		tag:     "keepalive/" + name,
+		opts:    o,
+		peers:   make(map[peer.ID]time.Time),
+		now:     time.Now,
+		stopped: make(chan struct{}),
+	}
+
+	k.ctx, k.cancel = context.WithCancel(ctx)
+
+	var err error
+	k.subs, err = p.PubSubSubscribeContext(
+		k.ctx,
+		name,
+		func(msg *pubsub.Message) {
+			if msg.ReceivedFrom != p.id {
+				k.seen(msg.ReceivedFrom)
+			}
+		},
+		func(err error) {
+			k.cancel()
+		},
+	)
+	if err != nil {
+		k.cancel()
+		return nil, err
+	}
+
+	go k.run()
+
+	return k, nil
+}
+
+func (k *KeepAlive) run() {
+	defer close(k.stopped)
+	defer k.untagAll()
+	defer k.cancel()
+
+	ticker := time.NewTicker(k.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.ctx.Done():
+			return
+		case <-k.subs.Done():
+			return
+		case <-ticker.C:
+			k.node.PubSubPublish(k.ctx, k.name, []byte(k.name))
+			k.decay()
+		}
+	}
+}
+
+func (k *KeepAlive) seen(pid peer.ID) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.ctx.Err() != nil {
+		return
+	}
+
+	k.peers[pid] = k.now()
+	k.node.host.ConnManager().TagPeer(pid, k.tag, k.opts.weight)
+}
+
+// decay lowers the tag weight of peers according to how long they have been
+// silent, and untags the ones silent for longer than the TTL.
+func (k *KeepAlive) decay() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	now := k.now()
+	cm := k.node.host.ConnManager()
+	for pid, last := range k.peers {
+		left := k.opts.ttl - now.Sub(last)
+		if left <= 0 {
+			delete(k.peers, pid)
+			cm.UntagPeer(pid, k.tag)
+			continue
+		}
+
+		weight := int(int64(k.opts.weight) * int64(left) / int64(k.opts.ttl))
+		if weight < 1 {
+			weight = 1
+		}
+		cm.TagPeer(pid, k.tag, weight)
+	}
+}
+
+func (k *KeepAlive) untagAll() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	cm := k.node.host.ConnManager()
+	for pid := range k.peers {
+		cm.UntagPeer(pid, k.tag)
+	}
+	k.peers = make(map[peer.ID]time.Time)
+}
+
+// Topic returns the name of the keepalive topic.
+func (k *KeepAlive) Topic() string {
+	return k.name
+}
+
+// Tag returns the connection manager tag used for kept peers.
+func (k *KeepAlive) Tag() string {
+	return k.tag
+}
+
+// Peers returns the peers currently kept, sorted.
+func (k *KeepAlive) Peers() []peer.ID {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	now := k.now()
+	peers := make([]peer.ID, 0, len(k.peers))
+	for pid, last := range k.peers {
+		if now.Sub(last) < k.opts.ttl {
+			peers = append(peers, pid)
+		}
+	}
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+
+	return peers
+}
+
+// Done is closed once the keepalive has stopped and untagged its peers.
+func (k *KeepAlive) Done() <-chan struct{} {
+	return k.stopped
+}
+
+// Close stops the keepalive and untags the kept peers.
+func (k *KeepAlive) Close() {
+	k.cancel()
+	<-k.stopped
+}
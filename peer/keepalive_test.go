@@ -0,0 +1,123 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestKeepAlive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	p1 := MockNode(ctx)
+	defer p1.Close()
+	p2 := MockNode(ctx)
+	defer p2.Close()
+
+	if err := mocknet.LinkAll(); err != nil {
+		t.Errorf("LinkAll returned error `%s`", err.Error())
+		return
+	}
+
+	if _, err := mocknet.ConnectPeers(p1.ID(), p2.ID()); err != nil {
+		t.Errorf("ConnectPeers returned error `%s`", err.Error())
+		return
+	}
+
+	opts := []KeepAliveOption{KeepAliveInterval(50 * time.Millisecond), KeepAliveTTL(300 * time.Millisecond)}
+	k1, err := p1.KeepAlive(ctx, "keepalive", opts...)
+	if err != nil {
+		t.Errorf("KeepAlive returned error `%s`", err.Error())
+		return
+	}
+	defer k1.Close()
+
+	k2, err := p2.KeepAlive(ctx, "keepalive", opts...)
+	if err != nil {
+		t.Errorf("KeepAlive returned error `%s`", err.Error())
+		return
+	}
+
+	if k1.Tag() != "keepalive/keepalive" {
+		t.Errorf("unexpected tag `%s`", k1.Tag())
+	}
+
+	waitFor := func(k *KeepAlive, expected []peer.ID) bool {
+		for {
+			peers := k.Peers()
+			if len(peers) == len(expected) && (len(peers) == 0 || peers[0] == expected[0]) {
+				return true
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Errorf("expected kept peers %v, got %v", expected, peers)
+				return false
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}
+
+	if !waitFor(k1, []peer.ID{p2.ID()}) || !waitFor(k2, []peer.ID{p1.ID()}) {
+		return
+	}
+
+	k2.Close()
+	select {
+	case <-k2.Done():
+	default:
+		t.Error("Done should be closed once Close returns")
+	}
+
+	if len(k2.Peers()) != 0 {
+		t.Error("closed keepalive should not keep peers")
+	}
+
+	// p2 is silent, it expires from p1 after the ttl
+	waitFor(k1, nil)
+}
+
+func TestKeepAliveDecay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p := MockNode(ctx)
+	defer p.Close()
+
+	k, err := p.KeepAlive(ctx, "decay", KeepAliveInterval(time.Hour), KeepAliveTTL(time.Minute))
+	if err != nil {
+		t.Errorf("KeepAlive returned error `%s`", err.Error())
+		return
+	}
+	defer k.Close()
+
+	now := time.Now()
+	k.now = func() time.Time { return now }
+
+	pid := peer.ID("other")
+	k.seen(pid)
+	if peers := k.Peers(); len(peers) != 1 || peers[0] != pid {
+		t.Errorf("unexpected kept peers %v", peers)
+		return
+	}
+
+	now = now.Add(30 * time.Second)
+	k.decay()
+	if len(k.Peers()) != 1 {
+		t.Error("peer expired before the ttl")
+		return
+	}
+
+	now = now.Add(30 * time.Second)
+	k.decay()
+	if len(k.Peers()) != 0 {
+		t.Error("peer was kept after the ttl")
+	}
+
+	if _, err = p.KeepAlive(ctx, "decay", KeepAliveTTL(0)); err == nil {
+		t.Error("expected an error for a zero ttl")
+	}
+}
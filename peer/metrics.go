@@ -0,0 +1,103 @@
+package peer
+
+import (
+	"errors"
+
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/taubyte/p2p/metrics"
+)
+
+// WithMetrics makes the node report its measures to m. The datastore of the
+// node is measured too. With a *metrics.Prometheus, libp2p metrics are
+// exported to its registry as well.
+func WithMetrics(m metrics.Metrics) Option {
+	return func(o *options) error {
+		if m == nil {
+			return errors.New("metrics is nil")
+		}
+
+		o.metrics = m
+		return nil
+	}
+}
+
+// Metrics returns the metrics the node reports to, metrics.Noop if none.
+func (p *node) Metrics() metrics.Metrics {
+	if p.metrics == nil {
+		return metrics.Noop{}
+	}
+
+	return p.metrics
+}
+
+// libp2pMetrics returns the option exporting libp2p metrics to the registry
+// of m, if it has one.
+func libp2pMetrics(m metrics.Metrics) libp2p.Option {
+	if r, ok := m.(interface{ Registry() *prometheus.Registry }); ok {
+		return libp2p.PrometheusRegisterer(r.Registry())
+	}
+
+	return nil
+}
+
+func direction(dir network.Direction) metrics.Direction {
+	if dir == network.DirInbound {
+		return metrics.Inbound
+	}
+
+	return metrics.Outbound
+}
+
+// measureConnections reports the connections of the host.
+func (p *node) measureConnections() {
+	if p.metrics == nil {
+		return
+	}
+
+	p.host.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			p.metrics.Connection(direction(c.Stat().Direction), true)
+		},
+		DisconnectedF: func(_ network.Network, c network.Conn) {
+			p.metrics.Connection(direction(c.Stat().Direction), false)
+		},
+	})
+}
+
+// pubsubMetrics is a pubsub.RawTracer reporting delivered messages.
+type pubsubMetrics struct {
+	metrics metrics.Metrics
+	self    peer.ID
+}
+
+var _ pubsub.RawTracer = pubsubMetrics{}
+
+// DeliverMessage reports messages from peers only, like pubsubStats: published
+// messages the node delivers to itself are already reported as outbound.
+func (t pubsubMetrics) DeliverMessage(msg *pubsub.Message) {
+	if msg.ReceivedFrom == t.self {
+		return
+	}
+
+	t.metrics.PubSubMessage(msg.GetTopic(), metrics.Inbound, len(msg.GetData()))
+}
+
+func (pubsubMetrics) AddPeer(peer.ID, protocol.ID)          {}
+func (pubsubMetrics) RemovePeer(peer.ID)                    {}
+func (pubsubMetrics) Join(string)                           {}
+func (pubsubMetrics) Leave(string)                          {}
+func (pubsubMetrics) Graft(peer.ID, string)                 {}
+func (pubsubMetrics) Prune(peer.ID, string)                 {}
+func (pubsubMetrics) ValidateMessage(*pubsub.Message)       {}
+func (pubsubMetrics) RejectMessage(*pubsub.Message, string) {}
+func (pubsubMetrics) DuplicateMessage(*pubsub.Message)      {}
+func (pubsubMetrics) ThrottlePeer(peer.ID)                  {}
+func (pubsubMetrics) RecvRPC(*pubsub.RPC)                   {}
+func (pubsubMetrics) SendRPC(*pubsub.RPC, peer.ID)          {}
+func (pubsubMetrics) DropRPC(*pubsub.RPC, peer.ID)          {}
+func (pubsubMetrics) UndeliverableMessage(*pubsub.Message)  {}
@@ -0,0 +1,128 @@
+package peer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/taubyte/p2p/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := metrics.NewPrometheus(nil)
+	if err != nil {
+		t.Errorf("NewPrometheus returned error `%s`", err.Error())
+		return
+	}
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithMetrics(m))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	if p1.Metrics() != m {
+		t.Error("node does not report to its metrics")
+	}
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	if _, ok := p2.Metrics().(metrics.Noop); !ok {
+		t.Errorf("expected no metrics, got %T", p2.Metrics())
+	}
+
+	if err = p1.Peer().Connect(ctx, peer.AddrInfo{ID: p2.ID(), Addrs: p2.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	if err = p1.PubSubPublish(ctx, "metrics", []byte("hello")); err != nil {
+		t.Errorf("PubSubPublish returned error `%s`", err.Error())
+		return
+	}
+
+	if _, err = p1.AddFile(bytes.NewBufferString("file")); err != nil {
+		t.Errorf("AddFile returned error `%s`", err.Error())
+		return
+	}
+
+	if _, err = p1.GetFile(ctx, "not a cid"); err == nil {
+		t.Error("expected an error getting an invalid cid")
+	}
+
+	var body string
+	expected := []string{
+		`taubyte_p2p_connections{direction="outbound"} 1`,
+		`taubyte_p2p_pubsub_messages_total{direction="outbound",topic="metrics"} 1`,
+		`taubyte_p2p_pubsub_bytes_total{direction="outbound",topic="metrics"} 5`,
+		`taubyte_p2p_file_op_duration_seconds_count{op="add"} 1`,
+		`taubyte_p2p_file_errors_total{op="get"} 1`,
+		`taubyte_p2p_datastore_op_duration_seconds_count{op="put"}`,
+		// libp2p metrics share the registry
+		`libp2p_swarm_`,
+	}
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(100 * time.Millisecond) {
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		data, _ := io.ReadAll(rec.Body)
+		body = string(data)
+
+		missing := false
+		for _, line := range expected {
+			missing = missing || !strings.Contains(body, line)
+		}
+		if !missing {
+			return
+		}
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("missing `%s`", line)
+		}
+	}
+}
+
+type pubsubRecorder struct {
+	metrics.Noop
+	inbound int
+}
+
+func (r *pubsubRecorder) PubSubMessage(_ string, dir metrics.Direction, _ int) {
+	if dir == metrics.Inbound {
+		r.inbound++
+	}
+}
+
+func TestPubSubMetricsSelf(t *testing.T) {
+	self, remote := MockNode(context.Background()), MockNode(context.Background())
+	defer self.Close()
+	defer remote.Close()
+
+	r := &pubsubRecorder{}
+	tracer := pubsubMetrics{metrics: r, self: self.ID()}
+
+	topic := "metrics"
+	tracer.DeliverMessage(&pubsub.Message{Message: &pb.Message{Topic: &topic, Data: []byte("self")}, ReceivedFrom: self.ID()})
+	tracer.DeliverMessage(&pubsub.Message{Message: &pb.Message{Topic: &topic, Data: []byte("remote")}, ReceivedFrom: remote.ID()})
+
+	if r.inbound != 1 {
+		t.Errorf("expected only the message of the remote peer to be inbound, got %d", r.inbound)
+	}
+}
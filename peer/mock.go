@@ -7,9 +7,11 @@ import (
 	ipfslite "github.com/hsanjuan/ipfs-lite"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	discovery "github.com/libp2p/go-libp2p/p2p/discovery/routing"
 	netmock "github.com/libp2p/go-libp2p/p2p/net/mock"
 	"github.com/taubyte/p2p/datastores/mem"
+	"github.com/taubyte/p2p/metrics"
 )
 
 var (
@@ -17,7 +19,16 @@ var (
 	mocknetLock sync.Mutex
 )
 
-func MockNode(ctx context.Context) Node {
+// MockNode creates a node on a shared mock network. Only the pubsub, gater,
+// metrics and tracing options are honored.
+func MockNode(ctx context.Context, opts ...Option) Node {
+	var o options
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			panic(err)
+		}
+	}
+
 	mocknetLock.Lock()
 	if mocknet == nil {
 		mocknet = netmock.New()
@@ -30,13 +41,32 @@ func MockNode(ctx context.Context) Node {
 	)
 
 	p.ctx, p.ctx_cancel = context.WithCancel(ctx)
+	p.events = newEventHub()
 
 	p.store = mem.New()
+	p.tracerProvider = o.tracerProvider
+	if o.metrics != nil {
+		p.metrics = o.metrics
+		p.store = metrics.WrapDatastore(p.store, p.metrics)
+	}
 
 	p.host, err = mocknet.GenPeer()
 	if err != nil {
 		panic(err)
 	}
+	p.id = p.host.ID()
+	p.key = p.host.Peerstore().PrivKey(p.id)
+
+	// mock hosts can not be gated, bans only close connections
+	if p.gater, err = newGater(p.ctx, p.store, o.gater); err != nil {
+		panic(err)
+	}
+	p.gater.setHost(p.host)
+	p.measureConnections()
+
+	if err = p.watchHostEvents(); err != nil {
+		panic(err)
+	}
 
 	p.dht, err = dht.New(p.ctx, p.host)
 	if err != nil {
@@ -44,7 +74,9 @@ func MockNode(ctx context.Context) Node {
 	}
 
 	// Create ipfs node
-	p.ipfs, err = ipfslite.New(p.ctx, p.store, nil, p.host, p.dht, nil)
+	var ipfsCtx context.Context
+	ipfsCtx, p.ipfs_ctx_cancel = context.WithCancel(p.ctx)
+	p.ipfs, err = ipfslite.New(ipfsCtx, p.store, nil, p.host, p.dht, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -52,16 +84,16 @@ func MockNode(ctx context.Context) Node {
 	p.drouter = discovery.NewRoutingDiscovery(p.dht)
 
 	// Prep messaging PUBSUB
-	p.messaging, err = pubsub.NewGossipSub(
-		p.ctx,
-		p.host,
-		pubsub.WithFloodPublish(true),
-	)
-	if err != nil {
+	if err = p.setupPubSub(o.pubsubConfig()); err != nil {
 		panic(err)
 	}
 
 	p.topics = make(map[string]*pubsub.Topic)
+	p.topicRefs = make(map[string]int)
+	p.sharedTopics = make(map[string]struct{})
+	p.subscriptions = make(map[*Subscription]struct{})
+	p.streamHandlers = make(map[protocol.ID]struct{})
 
+	p.setState(StateRunning)
 	return &p
 }
@@ -0,0 +1,422 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p"
+	record "github.com/libp2p/go-libp2p-record"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	helpers "github.com/taubyte/p2p/helpers"
+	"github.com/taubyte/p2p/metrics"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Profile selects the base set of libp2p options a node is built with.
+type Profile int
+
+const (
+	// ProfileSimple is the default profile, used by New and NewWithBootstrapList.
+	ProfileSimple Profile = iota
+	// ProfileClient is a lite private node with hole punching and NAT port mapping.
+	ProfileClient
+	// ProfileFull runs the NAT and relay services with a large connection manager.
+	ProfileFull
+	// ProfilePublic runs the NAT and relay services.
+	ProfilePublic
+	// ProfileLitePublic is a public node without NAT or relay services.
+	ProfileLitePublic
+)
+
+func (p Profile) String() string {
+	switch p {
+	case ProfileSimple:
+		return "simple"
+	case ProfileClient:
+		return "client"
+	case ProfileFull:
+		return "full"
+	case ProfilePublic:
+		return "public"
+	case ProfileLitePublic:
+		return "lite-public"
+	}
+
+	return fmt.Sprintf("profile(%d)", int(p))
+}
+
+// server reports whether nodes of this profile serve the network, in which
+// case announce addresses override the discovered ones.
+func (p Profile) server() bool {
+	switch p {
+	case ProfileFull, ProfilePublic, ProfileLitePublic:
+		return true
+	}
+
+	return false
+}
+
+func (p Profile) libp2pOptions() ([]libp2p.Option, error) {
+	var base []libp2p.Option
+	switch p {
+	case ProfileSimple:
+		base = helpers.Libp2pSimpleNodeOptions
+	case ProfileClient:
+		base = helpers.Libp2pLitePrivateNodeOptions
+	case ProfileFull:
+		base = helpers.Libp2pOptionsFullNode
+	case ProfilePublic:
+		base = helpers.Libp2pOptionsPublicNode
+	case ProfileLitePublic:
+		base = helpers.Libp2pOptionsLitePublicNode
+	default:
+		return nil, fmt.Errorf("unknown node profile %s", p)
+	}
+
+	opts := make([]libp2p.Option, len(base))
+	copy(opts, base)
+
+	return opts, nil
+}
+
+// Reachability forces how the node considers itself reachable by others.
+type Reachability int
+
+const (
+	// ReachabilityAuto lets AutoNAT detect reachability.
+	ReachabilityAuto Reachability = iota
+	// ReachabilityPrivate forces the node to consider itself behind a NAT.
+	ReachabilityPrivate
+	// ReachabilityPublic forces the node to consider itself publicly reachable.
+	ReachabilityPublic
+)
+
+func (r Reachability) libp2pOption() libp2p.Option {
+	switch r {
+	case ReachabilityPrivate:
+		return libp2p.ForceReachabilityPrivate()
+	case ReachabilityPublic:
+		return libp2p.ForceReachabilityPublic()
+	}
+
+	return nil
+}
+
+type options struct {
+	repoPath         string
+	key              crypto.PrivKey
+	swarmKey         []byte
+	listen           []string
+	transports       helpers.Transports
+	security         []helpers.Security
+	announce         []string
+	reachability     Reachability
+	profile          Profile
+	bootstrap        BootstrapParams
+	bootstrapPolicy  BootstrapPolicy
+	store            datastore.Batching
+	storeFactory     helpers.DatastoreFactory
+	storeOptions     helpers.DatastoreOptions
+	pubsub           *PubSubConfig
+	peering          peeringConfig
+	resources        ResourceLimits
+	gater            GaterConfig
+	dht              helpers.DHTConfig
+	recordNamespaces map[string]record.Validator
+	relayService     *RelayServiceConfig
+	staticRelays     []peer.AddrInfo
+	metrics          metrics.Metrics
+	tracerProvider   trace.TracerProvider
+	libp2pOptions    []libp2p.Option
+}
+
+// Option configures a node created with NewNode.
+type Option func(o *options) error
+
+// WithRepoPath sets the folder holding the node's data. If not set, an
+// ephemeral folder is created and removed when the node is closed.
+func WithRepoPath(path string) Option {
+	return func(o *options) error {
+		o.repoPath = path
+		return nil
+	}
+}
+
+// WithIdentity sets the private key of the node.
+func WithIdentity(key crypto.PrivKey) Option {
+	return func(o *options) error {
+		if key == nil {
+			return errors.New("identity key is nil")
+		}
+
+		o.key = key
+		return nil
+	}
+}
+
+// WithPrivateKey sets the private key of the node from its marshaled form.
+func WithPrivateKey(privateKey []byte) Option {
+	return func(o *options) (err error) {
+		o.key, err = crypto.UnmarshalPrivateKey(privateKey)
+		return
+	}
+}
+
+// WithSwarmKey sets the pre-shared key of a private swarm.
+func WithSwarmKey(swarmKey []byte) Option {
+	return func(o *options) error {
+		o.swarmKey = swarmKey
+		return nil
+	}
+}
+
+// WithListen adds addresses the node listens on.
+func WithListen(addrs ...string) Option {
+	return func(o *options) error {
+		o.listen = append(o.listen, addrs...)
+		return nil
+	}
+}
+
+// WithTransports sets the transports of the node. Defaults to
+// helpers.DefaultTransports. Listen addresses must match an enabled transport,
+// and QUIC or WebTransport can not be used with a swarm key.
+func WithTransports(transports helpers.Transports) Option {
+	return func(o *options) error {
+		if transports == 0 {
+			return errors.New("no transport enabled")
+		}
+
+		o.transports = transports
+		return nil
+	}
+}
+
+// WithSecurity sets the connection security protocols of the node, in order
+// of preference. Defaults to helpers.DefaultSecurity.
+func WithSecurity(protocols ...helpers.Security) Option {
+	return func(o *options) error {
+		if _, err := helpers.SecurityOptions(protocols...); err != nil {
+			return err
+		}
+
+		o.security = protocols
+		return nil
+	}
+}
+
+// WithAnnounce adds addresses the node announces to others.
+func WithAnnounce(addrs ...string) Option {
+	return func(o *options) error {
+		o.announce = append(o.announce, addrs...)
+		return nil
+	}
+}
+
+// WithReachability forces the reachability of the node.
+func WithReachability(reachability Reachability) Option {
+	return func(o *options) error {
+		o.reachability = reachability
+		return nil
+	}
+}
+
+// WithProfile sets the node profile. Defaults to ProfileSimple.
+func WithProfile(profile Profile) Option {
+	return func(o *options) error {
+		o.profile = profile
+		return nil
+	}
+}
+
+// WithBootstrap sets the bootstrap parameters. See Bootstrap and StandAlone.
+func WithBootstrap(bootstrap BootstrapParams) Option {
+	return func(o *options) error {
+		if err := o.bootstrapPolicy.validate(bootstrap); err != nil {
+			return err
+		}
+
+		o.bootstrap = bootstrap
+		return nil
+	}
+}
+
+// WithBootstrapPolicy sets the bootstrap policy. It only applies to nodes
+// bootstrapping, see Bootstrap.
+func WithBootstrapPolicy(policy BootstrapPolicy) Option {
+	return func(o *options) error {
+		if err := policy.validate(o.bootstrap); err != nil {
+			return err
+		}
+
+		o.bootstrapPolicy = policy
+		return nil
+	}
+}
+
+// WithDHT sets the DHT configuration of the node. Defaults to a dual DHT in
+// auto mode on the /ipfs protocols, see helpers.DHTConfig.
+func WithDHT(config helpers.DHTConfig) Option {
+	return func(o *options) error {
+		if err := config.Validate(); err != nil {
+			return err
+		}
+
+		o.dht = config
+		return nil
+	}
+}
+
+// dhtConfig returns the DHT configuration with the record namespaces.
+func (o *options) dhtConfig() (helpers.DHTConfig, error) {
+	config := o.dht
+	if len(o.recordNamespaces) == 0 {
+		return config, nil
+	}
+
+	validators := make(map[string]record.Validator, len(config.Validators)+len(o.recordNamespaces))
+	for ns, v := range config.Validators {
+		validators[ns] = v
+	}
+
+	for ns, v := range o.recordNamespaces {
+		if _, ok := validators[ns]; ok {
+			return config, fmt.Errorf("dht namespace `%s` is set twice", ns)
+		}
+		validators[ns] = v
+	}
+
+	config.Validators = validators
+	return config, config.Validate()
+}
+
+// WithDatastore makes the node use the provided datastore instead of opening
+// one in its repo. The datastore is not closed when the node is closed.
+func WithDatastore(store datastore.Batching) Option {
+	return func(o *options) error {
+		o.store = store
+		return nil
+	}
+}
+
+// WithDatastoreFactory makes the node open its datastore in its repo using
+// factory. Defaults to the pebble backend.
+func WithDatastoreFactory(factory helpers.DatastoreFactory) Option {
+	return func(o *options) error {
+		if factory == nil {
+			return errors.New("datastore factory is nil")
+		}
+
+		o.storeFactory = factory
+		return nil
+	}
+}
+
+// WithDatastoreBackend makes the node open its datastore using the backend
+// registered under name. See helpers.RegisterDatastore.
+func WithDatastoreBackend(name string) Option {
+	return func(o *options) (err error) {
+		o.storeFactory, err = helpers.GetDatastore(name)
+		return
+	}
+}
+
+// WithDatastoreOptions sets the options passed to the datastore factory.
+func WithDatastoreOptions(opts helpers.DatastoreOptions) Option {
+	return func(o *options) error {
+		o.storeOptions = opts
+		return nil
+	}
+}
+
+// WithPubSub sets the pubsub configuration. Defaults to DefaultPubSubConfig.
+func WithPubSub(config PubSubConfig) Option {
+	return func(o *options) error {
+		o.pubsub = &config
+		return nil
+	}
+}
+
+func (o *options) pubsubConfig() *PubSubConfig {
+	if o.pubsub != nil {
+		return o.pubsub
+	}
+
+	config := DefaultPubSubConfig()
+	return &config
+}
+
+// WithPeeringBackoff sets the backoff policy the peering service reconnects
+// with. Defaults to DefaultBackoffPolicy.
+func WithPeeringBackoff(policy BackoffPolicy) Option {
+	return func(o *options) error {
+		if policy == nil {
+			return errors.New("backoff policy is nil")
+		}
+
+		o.peering.backoff = policy
+		return nil
+	}
+}
+
+// WithPeeringResolver sets the resolver used for DNS addresses of peering
+// peers. Defaults to madns.DefaultResolver.
+func WithPeeringResolver(resolver Resolver) Option {
+	return func(o *options) error {
+		if resolver == nil {
+			return errors.New("resolver is nil")
+		}
+
+		o.peering.resolver = resolver
+		return nil
+	}
+}
+
+// WithPeeringResolveInterval sets how often DNS addresses of peering peers
+// are resolved again. Defaults to DefaultResolveInterval, a negative interval
+// only resolves them on failed reconnects.
+func WithPeeringResolveInterval(interval time.Duration) Option {
+	return func(o *options) error {
+		o.peering.resolveInterval = interval
+		return nil
+	}
+}
+
+// WithLibp2pOptions appends options used when building the libp2p host.
+func WithLibp2pOptions(opts ...libp2p.Option) Option {
+	return func(o *options) error {
+		o.libp2pOptions = append(o.libp2pOptions, opts...)
+		return nil
+	}
+}
+
+// libp2pConfig returns the config opts result in, to find what they set.
+func libp2pConfig(opts []libp2p.Option) (cfg libp2p.Config) {
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+
+	return
+}
+
+func legacyOptions(repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, bootstrap BootstrapParams) []Option {
+	opts := []Option{
+		WithPrivateKey(privateKey),
+		WithSwarmKey(swarmKey),
+		WithListen(swarmListen...),
+		WithAnnounce(swarmAnnounce...),
+		WithBootstrap(bootstrap),
+	}
+
+	if repoPath != nil {
+		opts = append(opts, WithRepoPath(fmt.Sprint(repoPath)))
+	}
+
+	return opts
+}
@@ -0,0 +1,111 @@
+package peer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taubyte/p2p/datastores/mem"
+	helpers "github.com/taubyte/p2p/helpers"
+	keypair "github.com/taubyte/p2p/keypair"
+)
+
+func TestNewNode(t *testing.T) {
+	ctx := context.Background()
+
+	store := mem.New()
+	p1, err := NewNode(
+		ctx,
+		WithPrivateKey(keypair.NewRaw()),
+		WithListen("/ip4/127.0.0.1/tcp/0"),
+		WithProfile(ProfileLitePublic),
+		WithReachability(ReachabilityPrivate),
+		WithDatastore(store),
+		WithBootstrap(StandAlone()),
+	)
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	if p1.Store() != store {
+		t.Error("node is not using the provided datastore")
+	}
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	p2.Peer().Peerstore().AddAddrs(p1.ID(), p1.Peer().Addrs(), time.Minute)
+	if _, _, err = p2.Ping(p1.ID().String(), 1); err != nil {
+		t.Errorf("Ping returned error `%s`", err.Error())
+	}
+}
+
+func TestNewNodeBadOption(t *testing.T) {
+	_, err := NewNode(context.Background(), WithPrivateKey([]byte("not a key")))
+	if err == nil {
+		t.Error("expected an error for an invalid private key")
+	}
+
+	_, err = NewNode(context.Background(), WithProfile(Profile(42)))
+	if err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestNewNodeDatastoreBackend(t *testing.T) {
+	ctx := context.Background()
+
+	for _, backend := range []string{helpers.DatastoreMem, helpers.DatastorePebble, helpers.DatastoreFlatfs} {
+		p, err := NewNode(
+			ctx,
+			WithListen("/ip4/127.0.0.1/tcp/0"),
+			WithDatastoreBackend(backend),
+			WithDatastoreOptions(helpers.DatastoreOptions{CacheSize: 8 << 20, SyncWrites: true}),
+		)
+		if err != nil {
+			t.Errorf("NewNode with `%s` returned error `%s`", backend, err.Error())
+			return
+		}
+
+		id, err := p.AddFile(strings.NewReader("hello " + backend))
+		if err != nil {
+			t.Errorf("AddFile on `%s` returned error `%s`", backend, err.Error())
+		} else if f, err := p.GetFile(ctx, id); err != nil {
+			t.Errorf("GetFile on `%s` returned error `%s`", backend, err.Error())
+		} else {
+			f.Close()
+		}
+
+		p.Close()
+	}
+
+	if _, err := NewNode(ctx, WithDatastoreBackend("unknown")); err == nil {
+		t.Error("expected an error for an unknown datastore backend")
+	}
+}
+
+func TestNewNodeFailureCleanup(t *testing.T) {
+	ctx := context.Background()
+	repo := t.TempDir()
+
+	// the host can not listen on an address of another machine
+	if _, err := NewNode(ctx, WithRepoPath(repo), WithListen("/ip4/192.0.2.1/tcp/0"), WithBootstrap(StandAlone())); err == nil {
+		t.Error("expected an error listening on a foreign address")
+		return
+	}
+
+	// the datastore of the failed node is closed, it can be opened again
+	p, err := NewNode(ctx, WithRepoPath(repo), WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	p.Close()
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p"
@@ -14,14 +15,19 @@ import (
 	"github.com/libp2p/go-libp2p-kad-dht/dual"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
 	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 
 	ipfslite "github.com/hsanjuan/ipfs-lite"
+	"github.com/ipfs/go-datastore"
 	dirutils "github.com/taubyte/utils/fs/dir"
 
 	"github.com/libp2p/go-libp2p/core/pnet"
 
 	helpers "github.com/taubyte/p2p/helpers"
+	"github.com/taubyte/p2p/metrics"
 
 	discoveryBackoff "github.com/libp2p/go-libp2p/p2p/discovery/backoff"
 	discovery "github.com/libp2p/go-libp2p/p2p/discovery/routing"
@@ -41,56 +47,149 @@ func init() {
 	crypto.MinRsaKeyBits = 1024
 }
 
+// Close closes the node, waiting at most CloseTimeout. Errors are logged.
+// Use CloseContext to get them.
 func (p *node) Close() {
-	err := p.cleanup()
-	if err != nil {
-		panic(err)
+	ctx, cancel := context.WithTimeout(context.Background(), CloseTimeout)
+	defer cancel()
+
+	if err := p.CloseContext(ctx); err != nil {
+		logger.Errorf("closing node failed with: %s", err)
 	}
+}
 
-	p.closed = true
+// CloseContext shuts the node down in order: it stops accepting streams and
+// waits for in-flight stream handlers, cancels pubsub subscriptions, stops
+// peering, closes the DHT, the host and ipfs-lite, flushes and closes the
+// datastore and finally removes the ephemeral repo. Waiting stops when ctx
+// is done, but the shutdown carries on. All errors are returned joined.
+func (p *node) CloseContext(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		p.setState(StateClosing)
+		p.closeErr = p.cleanup(ctx)
+		p.setState(StateClosed)
+		p.events.close()
+	})
+
+	return p.closeErr
 }
 
-func (p *node) cleanup() error {
+func (p *node) cleanup(ctx context.Context) error {
+	var errs []error
+
+	// Stream handlers
+	p.streamHandlersLock.Lock()
+	p.streamHandlersDraining = true
+	for pid := range p.streamHandlers {
+		p.host.RemoveStreamHandler(pid)
+	}
+	p.streamHandlersLock.Unlock()
+
+	if err := waitContext(ctx, &p.streamHandlersWG); err != nil {
+		errs = append(errs, fmt.Errorf("draining stream handlers failed with: %w", err))
+	}
+
+	// PubSub
 	p.topicsMutex.Lock()
-	defer p.topicsMutex.Unlock()
+	for subs := range p.subscriptions {
+		subs.Cancel()
+	}
+	p.subscriptions = nil
+	p.topicsMutex.Unlock()
+
+	if err := waitContext(ctx, &p.subscriptionsWG); err != nil {
+		errs = append(errs, fmt.Errorf("cancelling subscriptions failed with: %w", err))
+	}
 
+	p.topicsMutex.Lock()
+	for name, topic := range p.topics {
+		if err := topic.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing topic `%s` failed with: %w", name, err))
+		}
+	}
 	p.topics = nil
+	p.topicsMutex.Unlock()
 
-	if err := p.Peer().Close(); err != nil {
-		return err
+	if p.hostEvents != nil {
+		p.hostEvents.Close()
 	}
 
 	if p.peering != nil {
 		if err := p.peering.Stop(); err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("stopping peering failed with: %w", err))
 		}
 	}
+
 	if p.dht != nil {
 		// Need to determine the type of DHT then close it
-		switch p.dht.(type) {
+		var err error
+		switch d := p.dht.(type) {
 		case *dht.IpfsDHT:
-			if err := p.dht.(*dht.IpfsDHT).Close(); err != nil {
-				return err
-			}
+			err = d.Close()
 		case *dual.DHT:
-			if err := p.dht.(*dual.DHT).Close(); err != nil {
-				return err
-			}
+			err = d.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("closing dht failed with: %w", err))
+		}
+	}
+
+	if p.host != nil {
+		if err := p.host.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing host failed with: %w", err))
 		}
 	}
 
+	if p.ipfs_ctx_cancel != nil {
+		p.ipfs_ctx_cancel()
+	}
+
+	// ipfs-lite closes in the background, keep it off the datastore we close
+	if p.ipfs_store != nil {
+		p.ipfs_store.close()
+	}
+
 	if p.store != nil {
-		if err := p.store.Close(); err != nil {
-			return err
+		if err := p.store.Sync(ctx, datastore.NewKey("/")); err != nil {
+			errs = append(errs, fmt.Errorf("flushing datastore failed with: %w", err))
+		}
+
+		if !p.external_store {
+			if err := p.store.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("closing datastore failed with: %w", err))
+			}
 		}
 	}
+
 	if p.ephemeral_repo_path {
-		os.RemoveAll(p.repo_path)
+		if err := os.RemoveAll(p.repo_path); err != nil {
+			errs = append(errs, fmt.Errorf("removing repo failed with: %w", err))
+		}
 	}
 
 	p.ctx_cancel()
 
-	return nil
+	if p.pubsubTrace != nil {
+		p.pubsubTrace.Close()
+	}
+
+	return errors.Join(errs...)
+}
+
+// waitContext waits for wg, or until ctx is done.
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (p *node) Done() <-chan struct{} {
@@ -118,136 +217,282 @@ func (p *node) WaitForSwarm(timeout time.Duration) error {
 	}
 }
 
+// NewNode creates a node configured by the given options.
+func NewNode(ctx context.Context, opts ...Option) (Node, error) {
+	var o options
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	return new(ctx, &o)
+}
+
 func New(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, notPublic bool, bootstrap bool) (Node, error) {
-	opts := make([]libp2p.Option, len(helpers.Libp2pSimpleNodeOptions))
-	copy(opts, helpers.Libp2pSimpleNodeOptions)
+	opts := legacyOptions(repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, BootstrapParams{Enable: bootstrap})
 	if notPublic {
-		opts = append(opts, libp2p.ForceReachabilityPrivate())
+		opts = append(opts, WithReachability(ReachabilityPrivate))
 	}
 
-	return new(ctx, repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, BootstrapParams{Enable: bootstrap}, false, opts...)
+	return NewNode(ctx, opts...)
 }
 
 func NewClientNode(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, notPublic bool, bootstrapers []peer.AddrInfo) (Node, error) {
-	opts := make([]libp2p.Option, len(helpers.Libp2pLitePrivateNodeOptions))
-	copy(opts, helpers.Libp2pLitePrivateNodeOptions)
+	opts := legacyOptions(repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, BootstrapParams{Enable: true, Peers: bootstrapers})
+	opts = append(opts, WithProfile(ProfileClient))
 	if notPublic {
-		opts = append(opts, libp2p.ForceReachabilityPrivate())
+		opts = append(opts, WithReachability(ReachabilityPrivate))
 	}
 
-	return new(ctx, repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, BootstrapParams{Enable: true, Peers: bootstrapers}, false, opts...)
+	return NewNode(ctx, opts...)
 }
 
 func NewWithBootstrapList(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, notPublic bool, bootstrapers []peer.AddrInfo) (Node, error) {
-	opts := make([]libp2p.Option, len(helpers.Libp2pSimpleNodeOptions))
-	copy(opts, helpers.Libp2pSimpleNodeOptions)
+	opts := legacyOptions(repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, BootstrapParams{Enable: true, Peers: bootstrapers})
 	if notPublic {
-		opts = append(opts, libp2p.ForceReachabilityPrivate())
+		opts = append(opts, WithReachability(ReachabilityPrivate))
 	}
 
-	return new(ctx, repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, BootstrapParams{Enable: true, Peers: bootstrapers}, false, opts...)
+	return NewNode(ctx, opts...)
 }
 
 func NewFull(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, isPublic bool, bootstrap BootstrapParams) (Node, error) {
-	opts := make([]libp2p.Option, len(helpers.Libp2pOptionsFullNode))
-	copy(opts, helpers.Libp2pOptionsFullNode)
+	opts := legacyOptions(repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, bootstrap)
+	opts = append(opts, WithProfile(ProfileFull))
 	if isPublic {
-		opts = append(opts, libp2p.ForceReachabilityPublic())
+		opts = append(opts, WithReachability(ReachabilityPublic))
 	}
 
-	return new(ctx, repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, bootstrap, true, opts...)
+	return NewNode(ctx, opts...)
 }
 
 func NewPublic(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, bootstrap BootstrapParams) (Node, error) {
-	opts := make([]libp2p.Option, len(helpers.Libp2pOptionsPublicNode))
-	copy(opts, helpers.Libp2pOptionsPublicNode)
-	return new(ctx, repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, bootstrap, true, opts...)
+	opts := legacyOptions(repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, bootstrap)
+	return NewNode(ctx, append(opts, WithProfile(ProfilePublic))...)
 }
 
 func NewLitePublic(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, bootstrap BootstrapParams) (Node, error) {
-	opts := make([]libp2p.Option, len(helpers.Libp2pOptionsLitePublicNode))
-	copy(opts, helpers.Libp2pOptionsLitePublicNode)
-	return new(ctx, repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, bootstrap, true, opts...)
+	opts := legacyOptions(repoPath, privateKey, swarmKey, swarmListen, swarmAnnounce, bootstrap)
+	return NewNode(ctx, append(opts, WithProfile(ProfileLitePublic))...)
 }
 
-func new(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey []byte, swarmListen []string, swarmAnnounce []string, bootstrap BootstrapParams, server bool, opts ...libp2p.Option) (Node, error) {
-	var p node
-	var err error
+func new(ctx context.Context, o *options) (_ Node, err error) {
+	var (
+		p  node
+		rm network.ResourceManager
+	)
 
-	p.ctx, p.ctx_cancel = context.WithCancel(ctx)
+	p.events = newEventHub()
 
-	p.ephemeral_repo_path = false
-	if repoPath == nil {
-		repoPath, err = os.MkdirTemp("", "tb-node-*")
+	opts, err := o.profile.libp2pOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if ropt := o.reachability.libp2pOption(); ropt != nil {
+		opts = append(opts, ropt)
+		p.reachability.status = o.reachability.status()
+		p.reachability.forced = true
+	}
+
+	opts = append(opts, o.relayOptions()...)
+	p.tracerProvider = o.tracerProvider
+	if o.metrics != nil {
+		p.metrics = o.metrics
+		if mopt := libp2pMetrics(o.metrics); mopt != nil {
+			opts = append(opts, mopt)
+		}
+	}
+	opts = append(opts, o.libp2pOptions...)
+
+	// Read swarm key, transports are checked against it before anything is
+	// created
+	if o.swarmKey != nil {
+		p.secret, err = pnet.DecodeV1PSK(bytes.NewReader(o.swarmKey))
 		if err != nil {
 			return nil, err
 		}
-		p.ephemeral_repo_path = true
 	}
 
-	p.repo_path = fmt.Sprint(repoPath)
+	transports := o.transports
+	if transports == 0 {
+		transports = helpers.DefaultTransports
+	}
 
-	p.store, err = helpers.NewDatastore(p.repo_path)
+	if err = transports.ValidateListen(o.listen); err != nil {
+		return nil, err
+	}
+
+	if p.secret != nil && !transports.PrivateNetwork() {
+		return nil, fmt.Errorf("transports %s can not be used with a swarm key", transports)
+	}
+
+	transportOpts, err := transports.Options()
 	if err != nil {
 		return nil, err
 	}
 
-	// Read key
-	p.key, err = crypto.UnmarshalPrivateKey(privateKey)
+	security := o.security
+	if len(security) == 0 {
+		security = helpers.DefaultSecurity
+	}
+
+	securityOpts, err := helpers.SecurityOptions(security...)
 	if err != nil {
 		return nil, err
 	}
 
+	p.ctx, p.ctx_cancel = context.WithCancel(ctx)
+
+	// Release whatever was created if the node can not be started
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if p.host == nil && rm != nil {
+			rm.Close()
+		}
+
+		closeCtx, cancel := context.WithTimeout(context.Background(), CloseTimeout)
+		defer cancel()
+
+		if cerr := p.cleanup(closeCtx); cerr != nil {
+			logger.Errorf("cleaning up node failed with: %s", cerr)
+		}
+	}()
+
+	p.ephemeral_repo_path = false
+	p.repo_path = o.repoPath
+	if p.repo_path == "" {
+		p.repo_path, err = os.MkdirTemp("", "tb-node-*")
+		if err != nil {
+			return nil, err
+		}
+		p.ephemeral_repo_path = true
+	}
+
+	if o.store != nil {
+		p.store = o.store
+		p.external_store = true
+	} else {
+		factory := o.storeFactory
+		if factory == nil {
+			if factory, err = helpers.GetDatastore(helpers.DatastorePebble); err != nil {
+				return nil, err
+			}
+		}
+
+		p.store, err = factory(p.repo_path, o.storeOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.metrics != nil {
+		p.store = metrics.WrapDatastore(p.store, p.metrics)
+	}
+
+	p.key = o.key
+	if p.key == nil {
+		p.key, _, err = crypto.GenerateKeyPair(crypto.Ed25519, -1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate ID
 	p.id, err = peer.IDFromPublicKey(p.key.GetPublic())
 	if err != nil {
 		return nil, err
 	}
 
-	// Read swarm key
-	if swarmKey != nil {
-		p.secret, err = pnet.DecodeV1PSK(bytes.NewReader(swarmKey))
+	// https://github.com/libp2p/go-libp2p/blob/d4d6adff6e3260792cb4514c27368059f2558530/options.go
+	base := append(append([]libp2p.Option{}, helpers.Libp2pOptionsCommon...), transportOpts...)
+	base = append(base, securityOpts...)
+	opts = append(base, opts...)
+
+	opts = append(opts, libp2p.UserAgent(UserAgent), libp2p.EnableAutoNATv2(), p.traceHolePunching())
+	server := o.profile.server()
+	if server && len(o.announce) > 0 {
+		opts = append(opts, p.SimpleAddrsFactory(o.announce, server))
+	}
+
+	userConfig := libp2pConfig(o.libp2pOptions)
+
+	if userConfig.ResourceManager != nil {
+		logger.Warn("using the resource manager given in libp2p options, resource limits are ignored")
+	} else {
+		p.limiter = newLimiter(o.resources)
+		rm, err = rcmgr.NewResourceManager(p.limiter)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("creating resource manager failed with: %w", err)
 		}
+		opts = append(opts, libp2p.ResourceManager(rm))
 	}
 
-	// https://github.com/libp2p/go-libp2p/blob/d4d6adff6e3260792cb4514c27368059f2558530/options.go
-	if opts == nil {
-		opts = make([]libp2p.Option, 0)
+	bootstrap := o.bootstrap
+
+	dhtConfig, err := o.dhtConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	p.gater, err = newGater(p.ctx, p.store, o.gater)
+	if err != nil {
+		return nil, err
 	}
 
-	opts = append(helpers.Libp2pOptionsBase, opts...)
+	for _, pinfo := range bootstrap.Peers {
+		p.gater.Protect(pinfo.ID, bootstrapTag)
+	}
 
-	opts = append(opts, libp2p.UserAgent(UserAgent))
-	if server && swarmAnnounce != nil {
-		opts = append(opts, p.SimpleAddrsFactory(swarmAnnounce, server))
+	for _, pinfo := range o.staticRelays {
+		p.gater.Protect(pinfo.ID, relayTag)
+	}
+
+	if userConfig.ConnectionGater != nil {
+		logger.Warn("using the connection gater given in libp2p options, bans and gater rules are not enforced")
+	} else {
+		opts = append(opts, libp2p.ConnectionGater(p.gater))
 	}
 
 	bootstrapHandler := func() []peer.AddrInfo {
 		return bootstrap.Peers
 	}
 
-	p.host, p.dht, err = helpers.SetupLibp2p(
+	p.host, p.dht, err = helpers.SetupLibp2pWithDHT(
 		p.ctx,
 		p.key,
 		p.secret,
-		swarmListen,
+		o.listen,
 		p.store,
 		bootstrapHandler,
+		dhtConfig,
 		opts...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	p.gater.setHost(p.host)
+	p.measureConnections()
+
+	if err = p.watchHostEvents(); err != nil {
+		return nil, err
+	}
+
 	// Create ipfs node
-	p.ipfs, err = ipfslite.New(p.ctx, p.store, nil, p.host, p.dht, nil)
+	var ipfsCtx context.Context
+	ipfsCtx, p.ipfs_ctx_cancel = context.WithCancel(p.ctx)
+	p.ipfs_store = newIPFSStore(p.store)
+	p.ipfs, err = ipfslite.New(ipfsCtx, p.ipfs_store, nil, p.host, p.dht, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	p.peering = NewPeeringService(&p)
+	p.peering = newPeeringService(&p, o.peering)
 	err = p.peering.Start()
 	if err != nil {
 		return nil, err
@@ -255,19 +500,25 @@ func new(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey
 
 	if bootstrap.Enable {
 		// Bootstrap
-		bnodes, err := helpers.Bootstrap(p.ctx, p.host, p.dht, bootstrap.Peers)
+		report, err := p.bootstrap(bootstrap.Peers)
 		if err != nil {
 			return nil, err
 		}
 
-		// TODO: get the peering service out of bootsrap
-		for _, n := range bnodes {
-			p.peering.AddPeer(n)
+		policy := o.bootstrapPolicy
+		if len(report.Connected) < policy.MinPeers {
+			if !policy.Background {
+				return nil, fmt.Errorf("bootstrap reached %d of the %d required peers", len(report.Connected), policy.MinPeers)
+			}
+
+			logger.Warnf("bootstrap reached %d of the %d required peers, retrying in the background", len(report.Connected), policy.MinPeers)
+		}
+
+		if policy.Background || policy.Floor > 0 {
+			go p.maintainBootstrap(bootstrap.Peers, policy)
 		}
 	} else {
-		err := p.dht.Bootstrap(p.ctx)
-		if err != nil {
-			p.ctx_cancel()
+		if err = p.dht.Bootstrap(p.ctx); err != nil {
 			return nil, err
 		}
 	}
@@ -288,18 +539,16 @@ func new(ctx context.Context, repoPath interface{}, privateKey []byte, swarmKey
 	p.drouter = _drouter
 
 	// Prep messaging PUBSUB
-	p.messaging, err = pubsub.NewGossipSub(
-		p.ctx,
-		p.host,
-		pubsub.WithDiscovery(_drouter),
-		pubsub.WithFloodPublish(true),
-		pubsub.WithMessageSigning(true),
-		pubsub.WithStrictSignatureVerification(true),
-	)
-	if err != nil {
+	if err = p.setupPubSub(o.pubsubConfig(), pubsub.WithDiscovery(_drouter)); err != nil {
 		return nil, err
 	}
 
 	p.topics = make(map[string]*pubsub.Topic)
+	p.topicRefs = make(map[string]int)
+	p.sharedTopics = make(map[string]struct{})
+	p.subscriptions = make(map[*Subscription]struct{})
+	p.streamHandlers = make(map[protocol.ID]struct{})
+
+	p.setState(StateRunning)
 	return &p, nil
 }
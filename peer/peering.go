@@ -3,14 +3,18 @@ package peer
 import (
 	"context"
 	"errors"
-	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
 )
 
 // Seed the random number generator.
@@ -29,6 +33,31 @@ const (
 	initialDelay = 5 * time.Second
 )
 
+// peeringPrefix is where persisted peering entries are stored in the node
+// datastore.
+var peeringPrefix = datastore.NewKey("/peering")
+
+// clock abstracts time for the peering service, tests use a fake one.
+type clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) clockTimer
+}
+
+type clockTimer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	return time.AfterFunc(d, f)
+}
+
 type state int
 
 const (
@@ -47,20 +76,104 @@ type peerHandler struct {
 
 	mu             sync.Mutex
 	addrs          []multiaddr.Multiaddr
-	reconnectTimer *time.Timer
+	static         []multiaddr.Multiaddr
+	dns            []multiaddr.Multiaddr
+	resolver       Resolver
+	resolved       time.Time
+	generation     int
+	reconnectTimer clockTimer
+	restored       bool
+	backoff        BackoffPolicy
+	clock          clock
 
 	nextDelay time.Duration
+	nextRetry time.Time
+	attempts  int
+	connected bool
+	lastSeen  time.Time
+	events    *eventHub
+}
+
+// PeerStatus is the state of a peer maintained by the peering service.
+type PeerStatus struct {
+	ID peer.ID
+	// Addrs are the addresses dialed, DNS addresses are replaced by their
+	// last resolution.
+	Addrs []multiaddr.Multiaddr
+	// DNSAddrs are the DNS addresses the peer was added with.
+	DNSAddrs []multiaddr.Multiaddr
+	// Resolved is when DNSAddrs were last resolved.
+	Resolved time.Time
+	// Connected is set while at least one connection to the peer is open.
+	Connected bool
+	// LastSeen is when the peer last connected or disconnected.
+	LastSeen time.Time
+	// NextRetry is when the next reconnect attempt is scheduled, if any.
+	NextRetry time.Time
+	// Attempts is the number of reconnect attempts since the last connection.
+	Attempts int
+	// Backoff is the current delay between reconnect attempts, zero while
+	// connected.
+	Backoff time.Duration
+	// Persisted is set for peers restored from the node datastore on start.
+	// Peers added while running are persisted too, but not flagged.
+	Persisted bool
 }
 
-// setAddrs sets the addresses for this peer.
+func (ph *peerHandler) status() PeerStatus {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+
+	return PeerStatus{
+		ID:        ph.peer,
+		Addrs:     ph.addrs,
+		DNSAddrs:  ph.dns,
+		Resolved:  ph.resolved,
+		Connected: ph.connected,
+		LastSeen:  ph.lastSeen,
+		NextRetry: ph.nextRetry,
+		Attempts:  ph.attempts,
+		Backoff:   ph.nextDelay,
+		Persisted: ph.restored,
+	}
+}
+
+// setConnected records a connectedness change and emits the matching event.
+// mu must be held.
+func (ph *peerHandler) setConnected(connected bool) {
+	ph.lastSeen = ph.clock.Now()
+	if ph.connected == connected {
+		return
+	}
+
+	ph.connected = connected
+	if connected {
+		ph.events.emit(Event{Type: EventPeeringConnected, Peer: ph.peer})
+	} else {
+		ph.events.emit(Event{Type: EventPeeringDisconnected, Peer: ph.peer})
+	}
+}
+
+// setAddrs sets the addresses for this peer. DNS addresses are only dialed
+// once resolved.
 func (ph *peerHandler) setAddrs(addrs []multiaddr.Multiaddr) {
 	// Not strictly necessary, but it helps to not trust the calling code.
-	addrCopy := make([]multiaddr.Multiaddr, len(addrs))
-	copy(addrCopy, addrs)
+	var static, dns []multiaddr.Multiaddr
+	for _, addr := range addrs {
+		if madns.Matches(addr) {
+			dns = append(dns, addr)
+		} else {
+			static = append(static, addr)
+		}
+	}
 
 	ph.mu.Lock()
 	defer ph.mu.Unlock()
-	ph.addrs = addrCopy
+	ph.static = static
+	ph.dns = dns
+	ph.addrs = static
+	ph.resolved = time.Time{}
+	ph.generation++
 }
 
 // getAddrs returns a shared slice of addresses for this peer. Do not modify.
@@ -80,37 +193,54 @@ func (ph *peerHandler) stop() {
 		ph.reconnectTimer.Stop()
 		ph.reconnectTimer = nil
 	}
+	ph.nextRetry = time.Time{}
 }
 
+// nextBackoff computes the next delay with the backoff policy of the peer. mu
+// must be held.
 func (ph *peerHandler) nextBackoff() time.Duration {
-	if ph.nextDelay < maxBackoff {
-		ph.nextDelay += ph.nextDelay/2 + time.Duration(rand.Int63n(int64(ph.nextDelay)))
-	}
-
-	// If we've gone over the max backoff, reduce it under the max.
-	if ph.nextDelay > maxBackoff {
-		ph.nextDelay = maxBackoff
-		// randomize the backoff a bit (10%).
-		ph.nextDelay -= time.Duration(rand.Int63n(int64(maxBackoff) * maxBackoffJitter / 100))
-	}
-
+	ph.nextDelay = ph.backoff.Next(ph.nextDelay)
 	return ph.nextDelay
 }
 
+// setBackoff replaces the backoff policy of the peer. Pending attempts keep
+// their delay.
+func (ph *peerHandler) setBackoff(policy BackoffPolicy) {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+	ph.backoff = policy
+}
+
 func (ph *peerHandler) reconnect() {
 	// Try connecting
+	ph.mu.Lock()
+	ph.attempts++
+	resolve := len(ph.dns) > 0 && ph.resolved.IsZero()
+	ph.mu.Unlock()
+
+	if resolve {
+		ph.resolve()
+	}
+
 	addrs := ph.getAddrs()
 	logger.Debug("reconnecting", "peer", ph.peer, "addrs", addrs)
 
 	err := ph.host.Connect(ph.ctx, peer.AddrInfo{ID: ph.peer, Addrs: addrs})
+	if ph.node != nil {
+		ph.node.Metrics().PeeringReconnect(err)
+	}
 	if err != nil {
 		logger.Debug("failed to reconnect", "peer", ph.peer, "error", err)
+		// The peer may have moved behind its DNS name.
+		ph.resolve()
 		// Ok, we failed. Extend the timeout.
 		ph.mu.Lock()
 		if ph.reconnectTimer != nil {
 			// Only counts if the reconnectTimer still exists. If not, a
 			// connection _was_ somehow established.
-			ph.reconnectTimer.Reset(ph.nextBackoff())
+			delay := ph.nextBackoff()
+			ph.reconnectTimer.Reset(delay)
+			ph.nextRetry = ph.clock.Now().Add(delay)
 		}
 		// Otherwise, someone else has stopped us so we can assume that
 		// we're either connected or someone else will start us.
@@ -126,11 +256,18 @@ func (ph *peerHandler) stopIfConnected() {
 	ph.mu.Lock()
 	defer ph.mu.Unlock()
 
-	if ph.reconnectTimer != nil && ph.host.Network().Connectedness(ph.peer) == network.Connected {
+	if ph.host.Network().Connectedness(ph.peer) != network.Connected {
+		return
+	}
+
+	ph.setConnected(true)
+	if ph.reconnectTimer != nil {
 		logger.Debug("successfully reconnected", "peer", ph.peer)
 		ph.reconnectTimer.Stop()
 		ph.reconnectTimer = nil
-		ph.nextDelay = initialDelay
+		ph.nextDelay = 0
+		ph.nextRetry = time.Time{}
+		ph.attempts = 0
 	}
 }
 
@@ -139,10 +276,17 @@ func (ph *peerHandler) startIfDisconnected() {
 	ph.mu.Lock()
 	defer ph.mu.Unlock()
 
-	if ph.reconnectTimer == nil && ph.host.Network().Connectedness(ph.peer) != network.Connected {
+	if ph.host.Network().Connectedness(ph.peer) == network.Connected {
+		return
+	}
+
+	ph.setConnected(false)
+	if ph.reconnectTimer == nil && ph.ctx.Err() == nil {
 		logger.Debug("disconnected from peer", "peer", ph.peer)
 		// Always start with a short timeout so we can stagger things a bit.
-		ph.reconnectTimer = time.AfterFunc(ph.nextBackoff(), ph.reconnect)
+		delay := ph.nextBackoff()
+		ph.reconnectTimer = ph.clock.AfterFunc(delay, ph.reconnect)
+		ph.nextRetry = ph.clock.Now().Add(delay)
 	}
 }
 
@@ -152,15 +296,141 @@ type peeringService struct {
 	node *node
 	host host.Host
 
-	mu    sync.RWMutex
-	peers map[peer.ID]*peerHandler
-	state state
+	mu           sync.RWMutex
+	peers        map[peer.ID]*peerHandler
+	state        state
+	events       *eventHub
+	config       peeringConfig
+	clock        clock
+	resolveTimer clockTimer
+}
+
+// peeringConfig holds the node wide settings of the peering service.
+type peeringConfig struct {
+	backoff         BackoffPolicy
+	resolver        Resolver
+	resolveInterval time.Duration
+}
+
+type peeringOptions struct {
+	backoff BackoffPolicy
+}
+
+// PeeringOption configures a peer added with AddPeer.
+type PeeringOption func(o *peeringOptions)
+
+// PeerBackoff sets the backoff policy used to reconnect to the peer, instead
+// of the one of the service. It is not persisted.
+func PeerBackoff(policy BackoffPolicy) PeeringOption {
+	return func(o *peeringOptions) {
+		o.backoff = policy
+	}
+}
+
+type peeringEntry struct {
+	Addrs [][]byte `cbor:"1,keyasint"`
 }
 
 // NewPeeringService constructs a new peering service. Peers can be added and
 // removed immediately, but connections won't be formed until `Start` is called.
+// Peers persisted in the node datastore by previous runs are restored.
 func NewPeeringService(node *node) PeeringService {
-	return &peeringService{node: node, host: node.host, peers: make(map[peer.ID]*peerHandler)}
+	return newPeeringService(node, peeringConfig{})
+}
+
+// newPeeringService constructs a peering service, unset settings of config
+// are defaulted.
+func newPeeringService(node *node, config peeringConfig) *peeringService {
+	if config.backoff == nil {
+		config.backoff = DefaultBackoffPolicy
+	}
+
+	if config.resolver == nil {
+		config.resolver = madns.DefaultResolver
+	}
+
+	if config.resolveInterval == 0 {
+		config.resolveInterval = DefaultResolveInterval
+	}
+
+	ps := &peeringService{
+		node:   node,
+		host:   node.host,
+		peers:  make(map[peer.ID]*peerHandler),
+		events: newEventHub(),
+		config: config,
+		clock:  realClock{},
+	}
+
+	if err := ps.load(); err != nil {
+		logger.Errorf("loading peering list failed with: %s", err)
+	}
+
+	return ps
+}
+
+// load restores the peers persisted in the node datastore.
+func (ps *peeringService) load() error {
+	if ps.node.store == nil {
+		return nil
+	}
+
+	results, err := ps.node.store.Query(ps.node.ctx, query.Query{Prefix: peeringPrefix.String()})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+
+		key := datastore.RawKey(r.Key)
+		id, err := peer.Decode(key.BaseNamespace())
+		if err != nil {
+			logger.Warnf("ignoring peering entry `%s`: %s", r.Key, err)
+			continue
+		}
+
+		var entry peeringEntry
+		if err = cbor.Unmarshal(r.Value, &entry); err != nil {
+			logger.Warnf("ignoring peering entry `%s`: %s", r.Key, err)
+			continue
+		}
+
+		info := peer.AddrInfo{ID: id}
+		for _, b := range entry.Addrs {
+			addr, err := multiaddr.NewMultiaddrBytes(b)
+			if err != nil {
+				logger.Warnf("ignoring address of peering entry `%s`: %s", r.Key, err)
+				continue
+			}
+			info.Addrs = append(info.Addrs, addr)
+		}
+
+		ps.addPeer(info, true, nil)
+	}
+
+	return nil
+}
+
+func (ps *peeringService) persist(info peer.AddrInfo) error {
+	if ps.node.store == nil {
+		return nil
+	}
+
+	entry := peeringEntry{Addrs: make([][]byte, 0, len(info.Addrs))}
+	for _, addr := range info.Addrs {
+		entry.Addrs = append(entry.Addrs, addr.Bytes())
+	}
+
+	data, err := cbor.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return ps.node.store.Put(ps.node.ctx, peeringPrefix.ChildString(info.ID.String()), data)
 }
 
 // Start starts the peering service, connecting and maintaining connections to
@@ -183,6 +453,9 @@ func (ps *peeringService) Start() error {
 	for _, handler := range ps.peers {
 		go handler.startIfDisconnected()
 	}
+	if ps.config.resolveInterval > 0 {
+		ps.resolveTimer = ps.clock.AfterFunc(ps.config.resolveInterval, ps.resolveAll)
+	}
 	return nil
 }
 
@@ -199,46 +472,86 @@ func (ps *peeringService) Stop() error {
 		for _, handler := range ps.peers {
 			handler.stop()
 		}
+		if ps.resolveTimer != nil {
+			ps.resolveTimer.Stop()
+		}
 		ps.state = stateStopped
+		ps.events.close()
 	}
 	return nil
 }
 
-// AddPeer adds a peer to the peering service. This function may be safely
-// called at any time: before the service is started, while running, or after it
-// stops.
+// AddPeer adds a peer to the peering service and persists it in the node
+// datastore. This function may be safely called at any time: before the
+// service is started, while running, or after it stops.
 //
 // Add peer may also be called multiple times for the same peer. The new
-// addresses will replace the old.
-func (ps *peeringService) AddPeer(info peer.AddrInfo) {
+// addresses will replace the old, as will the backoff policy if one is given.
+func (ps *peeringService) AddPeer(info peer.AddrInfo, opts ...PeeringOption) {
+	var o peeringOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
+	if err := ps.persist(info); err != nil {
+		logger.Errorf("persisting peer %s failed with: %s", info.ID, err)
+	}
+
+	ps.addPeer(info, false, o.backoff)
+}
+
+// addPeer adds or updates a peer, restored tells if it was loaded from the node
+// datastore. A nil backoff keeps the current policy of the peer, or the one of
+// the service for new peers. mu must be held.
+func (ps *peeringService) addPeer(info peer.AddrInfo, restored bool, backoff BackoffPolicy) {
 	if handler, ok := ps.peers[info.ID]; ok {
 		logger.Info("updating addresses", "peer", info.ID, "addrs", info.Addrs)
 		handler.setAddrs(info.Addrs)
-	} else {
-		logger.Info("peer added", "peer", info.ID, "addrs", info.Addrs)
-		ps.host.ConnManager().Protect(info.ID, connmgrTag)
-
-		handler = &peerHandler{
-			node:      ps.node,
-			host:      ps.host,
-			peer:      info.ID,
-			addrs:     info.Addrs,
-			nextDelay: initialDelay,
+		if backoff != nil {
+			handler.setBackoff(backoff)
 		}
-		handler.ctx, handler.cancel = context.WithCancel(context.Background())
-		ps.peers[info.ID] = handler
-		switch ps.state {
-		case stateRunning:
-			go handler.startIfDisconnected()
-		case stateStopped:
-			// We still construct everything in this state because
-			// it's easier to reason about. But we should still free
-			// resources.
-			handler.cancel()
+		if restored {
+			handler.mu.Lock()
+			handler.restored = true
+			handler.mu.Unlock()
 		}
+		return
+	}
+
+	logger.Info("peer added", "peer", info.ID, "addrs", info.Addrs)
+	ps.host.ConnManager().Protect(info.ID, connmgrTag)
+	if ps.node.gater != nil {
+		ps.node.gater.Protect(info.ID, connmgrTag)
+	}
+
+	if backoff == nil {
+		backoff = ps.config.backoff
+	}
+
+	handler := &peerHandler{
+		node:     ps.node,
+		host:     ps.host,
+		peer:     info.ID,
+		restored: restored,
+		backoff:  backoff,
+		clock:    ps.clock,
+		resolver: ps.config.resolver,
+		events:   ps.events,
+	}
+	handler.setAddrs(info.Addrs)
+	handler.ctx, handler.cancel = context.WithCancel(context.Background())
+	ps.peers[info.ID] = handler
+	switch ps.state {
+	case stateRunning:
+		go handler.startIfDisconnected()
+	case stateStopped:
+		// We still construct everything in this state because
+		// it's easier to reason about. But we should still free
+		// resources.
+		handler.cancel()
 	}
 }
 
@@ -252,10 +565,41 @@ func (ps *peeringService) RemovePeer(id peer.ID) {
 	if handler, ok := ps.peers[id]; ok {
 		logger.Info("peer removed", "peer", id)
 		ps.host.ConnManager().Unprotect(id, connmgrTag)
+		if ps.node.gater != nil {
+			ps.node.gater.Unprotect(id, connmgrTag)
+		}
 
 		handler.stop()
 		delete(ps.peers, id)
+
+		if ps.node.store != nil {
+			if err := ps.node.store.Delete(ps.node.ctx, peeringPrefix.ChildString(id.String())); err != nil {
+				logger.Errorf("removing persisted peer %s failed with: %s", id, err)
+			}
+		}
+	}
+}
+
+// ListPeers returns the status of every peer of the service.
+func (ps *peeringService) ListPeers() []PeerStatus {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	list := make([]PeerStatus, 0, len(ps.peers))
+	for _, handler := range ps.peers {
+		list = append(list, handler.status())
 	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list
+}
+
+// Events returns a channel of EventPeeringConnected and
+// EventPeeringDisconnected events for the peers of the service. It is closed
+// when ctx is done or once the service is stopped.
+func (ps *peeringService) Events(ctx context.Context) <-chan Event {
+	return ps.events.subscribe(ctx)
 }
 
 type netNotifee peeringService
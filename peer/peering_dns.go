@@ -0,0 +1,195 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+// Resolver resolves /dns, /dns4, /dns6 and /dnsaddr multiaddrs of peering
+// peers. *madns.Resolver implements it.
+type Resolver interface {
+	Resolve(ctx context.Context, addr multiaddr.Multiaddr) ([]multiaddr.Multiaddr, error)
+}
+
+var (
+	// DefaultResolveInterval is how often DNS addresses of peering peers are
+	// resolved again, on top of every failed reconnect.
+	DefaultResolveInterval = 10 * time.Minute
+	// ResolveTimeout bounds the resolution of the DNS addresses of a peer.
+	ResolveTimeout = 10 * time.Second
+)
+
+// maxResolveDepth bounds the resolution of dnsaddr records pointing to other
+// DNS addresses.
+const maxResolveDepth = 4
+
+// resolve resolves the DNS addresses of the peer, if any. On failure, the
+// previously resolved addresses are kept.
+func (ph *peerHandler) resolve() {
+	ph.mu.Lock()
+	dns, static, generation := ph.dns, ph.static, ph.generation
+	ph.mu.Unlock()
+
+	if len(dns) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ph.ctx, ResolveTimeout)
+	defer cancel()
+
+	resolved, err := resolveAddrs(ctx, ph.resolver, ph.peer, dns, maxResolveDepth)
+	if err != nil {
+		logger.Debug("failed to resolve", "peer", ph.peer, "addrs", dns, "error", err)
+		return
+	}
+
+	addrs := make([]multiaddr.Multiaddr, 0, len(static)+len(resolved))
+	addrs = append(addrs, static...)
+	addrs = append(addrs, resolved...)
+
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+
+	// addresses were replaced meanwhile
+	if ph.generation != generation {
+		return
+	}
+
+	ph.addrs = addrs
+	ph.resolved = ph.clock.Now()
+}
+
+// resolveAddrs resolves addrs into transport addresses of id. It fails only if
+// none of addrs could be resolved.
+func resolveAddrs(ctx context.Context, resolver Resolver, id peer.ID, addrs []multiaddr.Multiaddr, depth int) ([]multiaddr.Multiaddr, error) {
+	p2p, err := multiaddr.NewComponent("p2p", id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		resolved []multiaddr.Multiaddr
+		lastErr  error
+		ok       bool
+	)
+	for _, addr := range addrs {
+		results, err := resolver.Resolve(ctx, addr.Encapsulate(p2p))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ok = true
+
+		var nested []multiaddr.Multiaddr
+		for _, r := range results {
+			transport, rid := peer.SplitAddr(r)
+			if transport == nil || (rid != "" && rid != id) {
+				continue
+			}
+
+			if madns.Matches(transport) {
+				nested = append(nested, transport)
+			} else {
+				resolved = append(resolved, transport)
+			}
+		}
+
+		if len(nested) > 0 && depth > 1 {
+			if more, err := resolveAddrs(ctx, resolver, id, nested, depth-1); err == nil {
+				resolved = append(resolved, more...)
+			}
+		}
+	}
+
+	if !ok {
+		return nil, lastErr
+	}
+
+	return resolved, nil
+}
+
+// resolveAll resolves the DNS addresses of all peers, then schedules the next
+// run.
+func (ps *peeringService) resolveAll() {
+	ps.mu.RLock()
+	handlers := make([]*peerHandler, 0, len(ps.peers))
+	for _, handler := range ps.peers {
+		handlers = append(handlers, handler)
+	}
+	ps.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler.resolve()
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.state == stateRunning {
+		ps.resolveTimer.Reset(ps.config.resolveInterval)
+	}
+}
+
+// AddPeerByName adds the peers found at name, either a multiaddr or a host
+// name looked up as /dnsaddr/<name>. DNS addresses are kept as given and
+// resolved again periodically and on every failed reconnect. If the address
+// does not end with /p2p/<id>, it is resolved once to find the peers it
+// points to.
+func (ps *peeringService) AddPeerByName(ctx context.Context, name string, opts ...PeeringOption) ([]peer.ID, error) {
+	if !strings.HasPrefix(name, "/") {
+		name = "/dnsaddr/" + name
+	}
+
+	addr, err := multiaddr.NewMultiaddr(name)
+	if err != nil {
+		return nil, fmt.Errorf("parsing `%s` failed with: %w", name, err)
+	}
+
+	transport, id := peer.SplitAddr(addr)
+	if transport == nil {
+		return nil, fmt.Errorf("`%s` has no address", name)
+	}
+
+	if id != "" {
+		ps.AddPeer(peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{transport}}, opts...)
+		return []peer.ID{id}, nil
+	}
+
+	if !madns.Matches(transport) {
+		return nil, fmt.Errorf("`%s` has no peer id", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ResolveTimeout)
+	defer cancel()
+
+	results, err := ps.config.resolver.Resolve(ctx, transport)
+	if err != nil {
+		return nil, fmt.Errorf("resolving `%s` failed with: %w", name, err)
+	}
+
+	var ids []peer.ID
+	seen := make(map[peer.ID]struct{})
+	for _, r := range results {
+		_, rid := peer.SplitAddr(r)
+		if rid == "" {
+			continue
+		}
+
+		if _, ok := seen[rid]; !ok {
+			seen[rid] = struct{}{}
+			ids = append(ids, rid)
+			ps.AddPeer(peer.AddrInfo{ID: rid, Addrs: []multiaddr.Multiaddr{transport}}, opts...)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("`%s` does not point to any peer", name)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,138 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+type fakeResolver struct {
+	lock    sync.Mutex
+	records map[string][]string
+}
+
+func (r *fakeResolver) set(name string, addrs ...string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.records[name] = addrs
+}
+
+func (r *fakeResolver) Resolve(ctx context.Context, addr multiaddr.Multiaddr) ([]multiaddr.Multiaddr, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	records, ok := r.records[addr.String()]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	addrs := make([]multiaddr.Multiaddr, 0, len(records))
+	for _, record := range records {
+		addrs = append(addrs, multiaddr.StringCast(record))
+	}
+
+	return addrs, nil
+}
+
+func TestPeeringDNS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p := MockNode(ctx)
+	defer p.Close()
+
+	// never linked, dialing it fails
+	other := MockNode(ctx)
+	defer other.Close()
+	id := other.ID().String()
+
+	resolver := &fakeResolver{records: make(map[string][]string)}
+	resolver.set("/dnsaddr/peers.example.com", "/dns4/a.example.com/tcp/4001/p2p/"+id)
+	resolver.set("/dnsaddr/peers.example.com/p2p/"+id, "/dns4/a.example.com/tcp/4001/p2p/"+id)
+	resolver.set("/dns4/a.example.com/tcp/4001/p2p/"+id, "/ip4/10.0.0.1/tcp/4001/p2p/"+id)
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	ps := newPeeringService(p.(*node), peeringConfig{
+		backoff:         ConstantBackoff(time.Hour),
+		resolver:        resolver,
+		resolveInterval: 5 * time.Minute,
+	})
+	ps.clock = clock
+	defer ps.Stop()
+
+	ids, err := ps.AddPeerByName(ctx, "peers.example.com")
+	if err != nil {
+		t.Errorf("AddPeerByName returned error `%s`", err.Error())
+		return
+	}
+
+	if len(ids) != 1 || ids[0] != other.ID() {
+		t.Errorf("unexpected peers %v", ids)
+		return
+	}
+
+	if list := ps.ListPeers(); len(list) != 1 || len(list[0].Addrs) != 0 || len(list[0].DNSAddrs) != 1 || list[0].DNSAddrs[0].String() != "/dnsaddr/peers.example.com" {
+		t.Errorf("unexpected peering list %+v", list)
+		return
+	}
+
+	if err = ps.Start(); err != nil {
+		t.Errorf("Start returned error `%s`", err.Error())
+		return
+	}
+
+	waitFor := func(addr string, attempts int) bool {
+		for {
+			list := ps.ListPeers()
+			if len(list) == 1 && len(list[0].Addrs) == 1 && list[0].Addrs[0].String() == addr && list[0].Attempts == attempts {
+				return true
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Errorf("expected %s after %d attempts, got %+v", addr, attempts, list)
+				return false
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	for len(ps.ListPeers()) != 1 || ps.ListPeers()[0].NextRetry.IsZero() {
+		select {
+		case <-ctx.Done():
+			t.Error("reconnect was never scheduled")
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	clock.Advance(5 * time.Minute)
+	if !waitFor("/ip4/10.0.0.1/tcp/4001", 0) {
+		return
+	}
+
+	resolver.set("/dns4/a.example.com/tcp/4001/p2p/"+id, "/ip4/10.0.0.2/tcp/4001/p2p/"+id)
+	clock.Advance(5 * time.Minute)
+	if !waitFor("/ip4/10.0.0.2/tcp/4001", 0) {
+		return
+	}
+
+	// the reconnect attempt fails and resolves again
+	resolver.set("/dns4/a.example.com/tcp/4001/p2p/"+id, "/ip4/10.0.0.3/tcp/4001/p2p/"+id)
+	clock.Advance(50 * time.Minute)
+	if !waitFor("/ip4/10.0.0.3/tcp/4001", 1) {
+		return
+	}
+
+	if _, err = ps.AddPeerByName(ctx, "unknown.example.com"); err == nil {
+		t.Error("expected an error for an unknown name")
+	}
+
+	if ids, err = ps.AddPeerByName(ctx, "/dns4/b.example.com/tcp/4001/p2p/"+p.ID().String()); err != nil || len(ids) != 1 || ids[0] != p.ID() {
+		t.Errorf("AddPeerByName returned %v, %v", ids, err)
+	}
+}
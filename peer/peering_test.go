@@ -0,0 +1,224 @@
+package peer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/taubyte/p2p/datastores/mem"
+)
+
+func TestPeeringPersisted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	store := mem.New()
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithDatastore(store), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	events := p1.Peering().Events(ctx)
+
+	info := peer.AddrInfo{ID: p2.ID(), Addrs: p2.Peer().Addrs()}
+	p1.Peering().AddPeer(info)
+
+	if err = p1.Peer().Connect(ctx, info); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	expect := func(typ EventType) bool {
+		for {
+			select {
+			case e := <-events:
+				if e.Type == typ && e.Peer == p2.ID() {
+					return true
+				}
+			case <-ctx.Done():
+				t.Errorf("did not get %s event", typ)
+				return false
+			}
+		}
+	}
+
+	if !expect(EventPeeringConnected) {
+		return
+	}
+
+	list := p1.Peering().ListPeers()
+	if len(list) != 1 || list[0].ID != p2.ID() || !list[0].Connected || list[0].Persisted || list[0].LastSeen.IsZero() {
+		t.Errorf("unexpected peering list %+v", list)
+		return
+	}
+
+	p2.Close()
+	if !expect(EventPeeringDisconnected) {
+		return
+	}
+
+	if list = p1.Peering().ListPeers(); len(list) != 1 || list[0].Connected || list[0].NextRetry.IsZero() {
+		t.Errorf("unexpected peering list %+v", list)
+		return
+	}
+
+	p1.Close()
+
+	p3, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithDatastore(store), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p3.Close()
+
+	list = p3.Peering().ListPeers()
+	if len(list) != 1 || list[0].ID != p2.ID() || !list[0].Persisted || len(list[0].Addrs) != len(info.Addrs) {
+		t.Errorf("peering list was not restored: %+v", list)
+		return
+	}
+
+	p3.Peering().RemovePeer(p2.ID())
+	if len(p3.Peering().ListPeers()) != 0 {
+		t.Error("peer was not removed")
+	}
+
+	if ok, _ := store.Has(ctx, peeringPrefix.ChildString(p2.ID().String())); ok {
+		t.Error("removed peer is still persisted")
+	}
+}
+
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	at     time.Time
+	f      func()
+	active bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f, active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward and fires the expired timers.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	var fire []func()
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.active = false
+			fire = append(fire, t.f)
+		}
+	}
+	c.lock.Unlock()
+
+	for _, f := range fire {
+		go f()
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	active := t.active
+	t.at = t.clock.now.Add(d)
+	t.active = true
+	return active
+}
+
+func TestPeeringBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p := MockNode(ctx)
+	defer p.Close()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	ps := newPeeringService(p.(*node), peeringConfig{backoff: ConstantBackoff(time.Minute)})
+	ps.clock = clock
+	defer ps.Stop()
+
+	global, custom := peer.ID("global"), peer.ID("custom")
+	ps.AddPeer(peer.AddrInfo{ID: global})
+	ps.AddPeer(peer.AddrInfo{ID: custom}, PeerBackoff(ConstantBackoff(2*time.Minute)))
+
+	if err := ps.Start(); err != nil {
+		t.Errorf("Start returned error `%s`", err.Error())
+		return
+	}
+
+	waitFor := func(check func(g, c PeerStatus) bool) bool {
+		for {
+			list := ps.ListPeers()
+			if len(list) == 2 {
+				c, g := list[0], list[1]
+				if check(g, c) {
+					return true
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Errorf("unexpected peering list %+v", list)
+				return false
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	start := clock.Now()
+	if !waitFor(func(g, c PeerStatus) bool {
+		return g.NextRetry.Equal(start.Add(time.Minute)) && c.NextRetry.Equal(start.Add(2*time.Minute))
+	}) {
+		return
+	}
+
+	clock.Advance(time.Minute)
+	if !waitFor(func(g, c PeerStatus) bool {
+		return g.Attempts == 1 && g.NextRetry.Equal(start.Add(2*time.Minute)) && c.Attempts == 0
+	}) {
+		return
+	}
+
+	clock.Advance(time.Minute)
+	waitFor(func(g, c PeerStatus) bool {
+		return g.Attempts == 2 && c.Attempts == 1 && c.NextRetry.Equal(start.Add(4*time.Minute)) && c.Backoff == 2*time.Minute
+	})
+}
@@ -12,7 +12,7 @@ import (
 var PingTimeout = time.Second * 4
 
 func (p *node) Ping(pid string, count int) (healthy int, rtt time.Duration, err error) {
-	if !p.closed {
+	if !p.isClosed() {
 		if count <= 0 {
 			return 0, 0, errors.New("ping count must be positive")
 		}
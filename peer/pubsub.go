@@ -2,169 +2,221 @@ package peer
 
 import (
 	"context"
-	"time"
 
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/taubyte/p2p/metrics"
 )
 
 type PubSubConsumerHandler func(msg *pubsub.Message)
 type PubSubConsumerErrorHandler func(err error)
 
+// NewPubSubKeepAlive runs a KeepAlive on topic name with the default settings
+// until ctx is done. cancel is called once it stops.
+//
+// Deprecated: use KeepAlive.
 func (p *node) NewPubSubKeepAlive(ctx context.Context, cancel context.CancelFunc, name string) error {
-	// Use a special pubsub topic to avoid disconnecting
-	// from globaldb peers.
-
-	if !p.closed {
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(20 * time.Second):
-					p.PubSubPublish(ctx, name, []byte(name))
-				}
-			}
-		}()
-
-		return p.PubSubSubscribe(
-			name,
-			func(msg *pubsub.Message) {
-				p.host.ConnManager().TagPeer(msg.ReceivedFrom, "keep", 100)
-			},
-			func(err error) {
-				cancel()
-			},
-		)
+	k, err := p.KeepAlive(ctx, name)
+	if err != nil {
+		return err
 	}
 
-	return errorClosed
+	go func() {
+		<-k.Done()
+		cancel()
+	}()
+
+	return nil
 }
 
 func (p *node) getOrCreateTopic(name string) (topic *pubsub.Topic, err error) {
-	if !p.closed {
+	if !p.isClosed() {
 		p.topicsMutex.Lock()
 		defer p.topicsMutex.Unlock()
 
-		var ok bool
-		topic, ok = p.topics[name]
-		if !ok {
-			if topic, err = p.messaging.Join(name); err != nil {
-				return
-			}
-
-			p.topics[name] = topic
-		}
-
-		return topic, nil
+		return p.joinTopic(name)
 	}
 
 	err = errorClosed
 	return
 }
 
-func (p *node) PubSubSubscribe(name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) error {
-	if !p.closed {
-		topic, err := p.getOrCreateTopic(name)
-		if err != nil {
-			return err
+// joinTopic returns the topic name, joining it if needed. topicsMutex must be
+// held.
+func (p *node) joinTopic(name string) (topic *pubsub.Topic, err error) {
+	if p.topics == nil {
+		return nil, errorClosed
+	}
+
+	var ok bool
+	topic, ok = p.topics[name]
+	if !ok {
+		if topic, err = p.messaging.Join(name); err != nil {
+			return
 		}
 
-		return p.PubSubSubscribeToTopic(topic, handler, err_handler)
+		p.topics[name] = topic
 	}
 
-	return errorClosed
+	return topic, nil
 }
 
-func (p *node) PubSubSubscribeToTopic(topic *pubsub.Topic, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) error {
-	if !p.closed {
-		subs, err := topic.Subscribe()
-		if err != nil {
-			return err
+// PubSubTopic returns the topic name, joining it if needed. The handle stays
+// open until the node closes, even after the last subscription is cancelled.
+func (p *node) PubSubTopic(name string) (*pubsub.Topic, error) {
+	if p.isClosed() {
+		return nil, errorClosed
+	}
+
+	p.topicsMutex.Lock()
+	defer p.topicsMutex.Unlock()
+
+	topic, err := p.joinTopic(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.sharedTopics[name] = struct{}{}
+
+	return topic, nil
+}
+
+// newSubscription subscribes to topic, or to the topic name joined by the
+// node if topic is nil. The subscription is cancelled when the node closes.
+func (p *node) newSubscription(name string, topic *pubsub.Topic) (*Subscription, error) {
+	p.topicsMutex.Lock()
+	defer p.topicsMutex.Unlock()
+
+	if p.subscriptions == nil {
+		return nil, errorClosed
+	}
+
+	if topic == nil {
+		var err error
+		if topic, err = p.joinTopic(name); err != nil {
+			return nil, err
 		}
+	}
+
+	subs, err := topic.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		node:  p,
+		topic: topic,
+		subs:  subs,
+		done:  make(chan struct{}),
+	}
 
-		go func() {
-			lookup := make(map[string]struct{})
-			max := 1024
-			order := make([]string, 0, max)
-
-			defer subs.Cancel()
-			for {
-				select {
-				case <-p.ctx.Done():
-					return
-				default:
-					msg, err := subs.Next(p.ctx)
-					if err != nil {
-						err_handler(err)
-						break
-					}
-
-					if _, ok := lookup[msg.ID]; ok {
-						continue
-					}
-
-					lookup[msg.ID] = struct{}{}
-					if len(order)+1 >= cap(order) {
-						delete(lookup, order[0])
-						order = order[1:]
-					}
-					order = append(order, msg.ID)
-
-					handler(msg)
+	// only topics joined by the node are left with their last subscription
+	if p.topics[topic.String()] == topic {
+		s.counted = true
+		p.topicRefs[topic.String()]++
+	}
+
+	p.subscriptions[s] = struct{}{}
+	p.subscriptionsWG.Add(1)
+
+	return s, nil
+}
+
+func (p *node) releaseSubscription(s *Subscription) {
+	s.subs.Cancel()
+
+	p.topicsMutex.Lock()
+	delete(p.subscriptions, s)
+	if s.counted {
+		name := s.topic.String()
+		if p.topicRefs[name]--; p.topicRefs[name] <= 0 {
+			delete(p.topicRefs, name)
+			// callers of PubSubTopic may still publish on the handle
+			if _, shared := p.sharedTopics[name]; !shared && p.topics[name] == s.topic {
+				// fails while other subscriptions or event handlers are active
+				if err := s.topic.Close(); err != nil {
+					logger.Debugf("keeping topic `%s`: %s", name, err)
+				} else {
+					delete(p.topics, name)
 				}
 			}
-		}()
+		}
+	}
+	p.topicsMutex.Unlock()
+
+	close(s.done)
+	p.subscriptionsWG.Done()
+}
+
+func (p *node) PubSubSubscribe(name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) (*Subscription, error) {
+	if !p.isClosed() {
+		s, err := p.newSubscription(name, nil)
+		if err != nil {
+			return nil, err
+		}
 
-		return nil
+		go s.run(p.ctx, true, handler, err_handler)
+
+		return s, nil
 	}
 
-	return errorClosed
+	return nil, errorClosed
 }
 
-// TODO: make PubSubSubscribe not recreate topics,  should cache and open.
-func (p *node) PubSubSubscribeContext(ctx context.Context, name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) error {
-	if !p.closed {
-		topic, err := p.getOrCreateTopic(name)
+func (p *node) PubSubSubscribeToTopic(topic *pubsub.Topic, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) (*Subscription, error) {
+	if !p.isClosed() {
+		s, err := p.newSubscription(topic.String(), topic)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		subs, err := topic.Subscribe()
+		go s.run(p.ctx, true, handler, err_handler)
+
+		return s, nil
+	}
+
+	return nil, errorClosed
+}
+
+func (p *node) PubSubSubscribeContext(ctx context.Context, name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) (*Subscription, error) {
+	if !p.isClosed() {
+		s, err := p.newSubscription(name, nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		go func() {
-			defer subs.Cancel()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					msg, err := subs.Next(ctx)
-					if err != nil {
-						err_handler(err)
-						break
-					}
-					handler(msg)
-				}
-			}
-		}()
+		go s.run(ctx, false, handler, err_handler)
 
-		return nil
+		return s, nil
 	}
 
-	return errorClosed
+	return nil, errorClosed
 }
 
 func (p *node) PubSubPublish(ctx context.Context, name string, data []byte) error {
-	if !p.closed {
+	if !p.isClosed() {
 		topic, err := p.getOrCreateTopic(name)
 		if err != nil {
 			return err
 		}
 
-		return topic.Publish(ctx, data)
+		err = topic.Publish(ctx, data)
+		if err == pubsub.ErrTopicClosed {
+			// the last subscription left the topic meanwhile, join it again
+			if topic, err = p.getOrCreateTopic(name); err != nil {
+				return err
+			}
+
+			err = topic.Publish(ctx, data)
+		}
+
+		if err == nil {
+			if p.pubsubStats != nil {
+				p.pubsubStats.published(name)
+			}
+			p.Metrics().PubSubMessage(name, metrics.Outbound, len(data))
+		}
+
+		return err
 	}
 
 	return errorClosed
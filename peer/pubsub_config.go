@@ -0,0 +1,146 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// PubSubRouter selects the pubsub routing protocol.
+type PubSubRouter int
+
+const (
+	PubSubRouterGossip PubSubRouter = iota
+	PubSubRouterFlood
+)
+
+func (r PubSubRouter) String() string {
+	switch r {
+	case PubSubRouterGossip:
+		return "gossipsub"
+	case PubSubRouterFlood:
+		return "floodsub"
+	}
+
+	return fmt.Sprintf("router(%d)", int(r))
+}
+
+// PubSubConfig configures the pubsub router of a node. Gossipsub specific
+// fields are ignored by floodsub. The zero value is the default: gossipsub
+// with flood publishing and strictly verified signatures.
+type PubSubConfig struct {
+	Router PubSubRouter
+
+	// GossipSubParams tunes the mesh. Nil keeps the gossipsub defaults.
+	GossipSubParams     *pubsub.GossipSubParams
+	DisableFloodPublish bool
+
+	// PeerScore enables peer scoring, PeerScoreThresholds must be set too.
+	PeerScore           *pubsub.PeerScoreParams
+	PeerScoreThresholds *pubsub.PeerScoreThresholds
+	// TopicScores are added to PeerScore.Topics.
+	TopicScores map[string]*pubsub.TopicScoreParams
+
+	// MessageIDFn computes message IDs. Nil keeps the default (sender and seqno).
+	MessageIDFn pubsub.MsgIdFunction
+	// DisableMessageSigning publishes unsigned messages.
+	DisableMessageSigning bool
+	// DisableStrictSignatureVerification accepts messages without a valid
+	// signature.
+	DisableStrictSignatureVerification bool
+
+	// Stats enables the counters returned by Node.PubSubStats.
+	Stats bool
+	// TraceFile is where to write a JSON trace of pubsub events, if set.
+	TraceFile string
+
+	// Options are passed as is to the router.
+	Options []pubsub.Option
+}
+
+// DefaultPubSubConfig returns the configuration nodes are built with by
+// default: gossipsub with flood publishing and strictly verified signatures.
+func DefaultPubSubConfig() PubSubConfig {
+	return PubSubConfig{}
+}
+
+func (c *PubSubConfig) options() ([]pubsub.Option, error) {
+	opts := []pubsub.Option{
+		pubsub.WithMessageSigning(!c.DisableMessageSigning),
+		pubsub.WithStrictSignatureVerification(!c.DisableStrictSignatureVerification),
+	}
+
+	if c.MessageIDFn != nil {
+		opts = append(opts, pubsub.WithMessageIdFn(c.MessageIDFn))
+	}
+
+	if c.Router == PubSubRouterGossip {
+		opts = append(opts, pubsub.WithFloodPublish(!c.DisableFloodPublish))
+
+		if c.GossipSubParams != nil {
+			opts = append(opts, pubsub.WithGossipSubParams(*c.GossipSubParams))
+		}
+
+		if c.PeerScore != nil {
+			if c.PeerScoreThresholds == nil {
+				return nil, errors.New("peer score needs thresholds")
+			}
+
+			params := *c.PeerScore
+			params.Topics = make(map[string]*pubsub.TopicScoreParams, len(c.PeerScore.Topics)+len(c.TopicScores))
+			for name, topic := range c.PeerScore.Topics {
+				params.Topics[name] = topic
+			}
+			for name, topic := range c.TopicScores {
+				params.Topics[name] = topic
+			}
+
+			opts = append(opts, pubsub.WithPeerScore(&params, c.PeerScoreThresholds))
+		} else if len(c.TopicScores) > 0 {
+			return nil, errors.New("topic scores need peer score")
+		}
+	}
+
+	return append(opts, c.Options...), nil
+}
+
+// setupPubSub creates the pubsub router of the node, along with its tracers.
+func (p *node) setupPubSub(c *PubSubConfig, extra ...pubsub.Option) error {
+	opts, err := c.options()
+	if err != nil {
+		return err
+	}
+
+	opts = append(extra, opts...)
+
+	if c.Stats {
+		p.pubsubStats = newPubSubStats(p.id)
+		opts = append(opts, pubsub.WithRawTracer(p.pubsubStats))
+		if c.Router == PubSubRouterGossip && c.PeerScore != nil {
+			opts = append(opts, pubsub.WithPeerScoreInspect(p.pubsubStats.inspectScores, PubSubScoreInspectPeriod))
+		}
+	}
+
+	if p.metrics != nil {
+		opts = append(opts, pubsub.WithRawTracer(pubsubMetrics{metrics: p.metrics, self: p.id}))
+	}
+
+	if c.TraceFile != "" {
+		if p.pubsubTrace, err = pubsub.NewJSONTracer(c.TraceFile); err != nil {
+			return err
+		}
+		opts = append(opts, pubsub.WithEventTracer(p.pubsubTrace))
+	}
+
+	switch c.Router {
+	case PubSubRouterGossip:
+		p.messaging, err = pubsub.NewGossipSub(p.ctx, p.host, opts...)
+	case PubSubRouterFlood:
+		p.messaging, err = pubsub.NewFloodSub(p.ctx, p.host, opts...)
+	default:
+		err = fmt.Errorf("unknown pubsub router %s", c.Router)
+	}
+
+	return err
+}
@@ -0,0 +1,105 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPubSubConfig(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	config := PubSubConfig{Router: PubSubRouterFlood}
+	p1 := MockNode(ctx, WithPubSub(config))
+	defer p1.Close()
+	p2 := MockNode(ctx, WithPubSub(config))
+	defer p2.Close()
+
+	if err := mocknet.LinkAll(); err != nil {
+		t.Errorf("LinkAll returned error `%s`", err.Error())
+		return
+	}
+
+	if _, err := mocknet.ConnectPeers(p1.ID(), p2.ID()); err != nil {
+		t.Errorf("ConnectPeers returned error `%s`", err.Error())
+		return
+	}
+
+	received := make(chan *pubsub.Message, 1)
+	if _, err := p1.PubSubSubscribe("flood", func(msg *pubsub.Message) { received <- msg }, func(error) {}); err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	topic, _ := p2.PubSubTopic("flood")
+	for len(topic.ListPeers()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Error("peers never joined the topic")
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err := p2.PubSubPublish(ctx, "flood", []byte("hello")); err != nil {
+		t.Errorf("Publish returned error `%s`", err.Error())
+		return
+	}
+
+	select {
+	case msg := <-received:
+		if string(msg.Data) != "hello" {
+			t.Errorf("unexpected message `%s`", msg.Data)
+		}
+
+		// messages are signed unless disabled
+		if len(msg.Signature) == 0 {
+			t.Error("message is not signed")
+		}
+	case <-ctx.Done():
+		t.Error("message was not received over floodsub")
+	}
+}
+
+func TestPubSubConfigScoring(t *testing.T) {
+	config := DefaultPubSubConfig()
+	params := pubsub.DefaultGossipSubParams()
+	params.D, params.Dlo, params.Dhi, params.Dout = 4, 3, 6, 1
+	config.GossipSubParams = &params
+	config.PeerScore = &pubsub.PeerScoreParams{
+		AppSpecificScore:            func(peer.ID) float64 { return 0 },
+		DecayInterval:               time.Second,
+		DecayToZero:                 0.01,
+		IPColocationFactorThreshold: 1,
+	}
+	config.PeerScoreThresholds = &pubsub.PeerScoreThresholds{
+		GossipThreshold:             -10,
+		PublishThreshold:            -50,
+		GraylistThreshold:           -80,
+		OpportunisticGraftThreshold: 1,
+	}
+	config.TopicScores = map[string]*pubsub.TopicScoreParams{
+		"scored": {
+			TopicWeight:                   1,
+			TimeInMeshQuantum:             time.Second,
+			FirstMessageDeliveriesDecay:   0.5,
+			InvalidMessageDeliveriesDecay: 0.5,
+		},
+	}
+
+	n, err := NewNode(context.Background(), WithListen("/ip4/127.0.0.1/tcp/0"), WithPubSub(config))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	n.Close()
+
+	config.PeerScore = nil
+	if _, err = NewNode(context.Background(), WithListen("/ip4/127.0.0.1/tcp/0"), WithPubSub(config)); err == nil {
+		t.Error("expected an error for topic scores without peer score")
+	}
+}
@@ -0,0 +1,196 @@
+package peer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// PubSubScoreInspectPeriod is how often peer scores are refreshed when both
+// stats and peer scoring are enabled.
+var PubSubScoreInspectPeriod = 10 * time.Second
+
+// TopicStats are the pubsub counters of a topic, as seen by the node.
+type TopicStats struct {
+	// Published is the number of messages published by the node.
+	Published uint64
+	// Delivered is the number of messages received from peers and delivered
+	// to local subscribers.
+	Delivered uint64
+	// Rejected is the number of messages rejected or ignored by validation,
+	// throttled messages excluded.
+	Rejected uint64
+	// Duplicates is the number of already seen messages dropped.
+	Duplicates uint64
+	// Throttled is the number of messages dropped because validation was
+	// throttled.
+	Throttled uint64
+	// Undeliverable is the number of messages dropped because a local
+	// subscriber was too slow.
+	Undeliverable uint64
+	// Mesh lists the peers in the node's mesh for the topic (gossipsub).
+	Mesh []peer.ID
+	// Scores are the last known peer scores, if peer scoring is enabled.
+	Scores map[peer.ID]float64
+}
+
+var errPubSubStatsDisabled = errors.New("pubsub stats are disabled")
+
+type topicCounters struct {
+	published     uint64
+	delivered     uint64
+	rejected      uint64
+	duplicates    uint64
+	throttled     uint64
+	undeliverable uint64
+	mesh          map[peer.ID]struct{}
+}
+
+// pubsubStats is a pubsub.RawTracer keeping per topic counters.
+type pubsubStats struct {
+	self   peer.ID
+	lock   sync.Mutex
+	topics map[string]*topicCounters
+	scores map[peer.ID]float64
+}
+
+var _ pubsub.RawTracer = (*pubsubStats)(nil)
+
+func newPubSubStats(self peer.ID) *pubsubStats {
+	return &pubsubStats{self: self, topics: make(map[string]*topicCounters)}
+}
+
+// topic returns the counters of name. lock must be held.
+func (s *pubsubStats) topic(name string) *topicCounters {
+	t, ok := s.topics[name]
+	if !ok {
+		t = &topicCounters{mesh: make(map[peer.ID]struct{})}
+		s.topics[name] = t
+	}
+
+	return t
+}
+
+func (s *pubsubStats) update(name string, fn func(t *topicCounters)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fn(s.topic(name))
+}
+
+func (s *pubsubStats) inspectScores(scores map[peer.ID]float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.scores = scores
+}
+
+func (s *pubsubStats) stats(name string) TopicStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.topics[name]
+	if !ok {
+		return TopicStats{}
+	}
+
+	stats := TopicStats{
+		Published:     t.published,
+		Delivered:     t.delivered,
+		Rejected:      t.rejected,
+		Duplicates:    t.duplicates,
+		Throttled:     t.throttled,
+		Undeliverable: t.undeliverable,
+		Mesh:          make([]peer.ID, 0, len(t.mesh)),
+	}
+
+	for pid := range t.mesh {
+		stats.Mesh = append(stats.Mesh, pid)
+	}
+
+	if s.scores != nil {
+		stats.Scores = make(map[peer.ID]float64, len(s.scores))
+		for pid, score := range s.scores {
+			stats.Scores[pid] = score
+		}
+	}
+
+	return stats
+}
+
+func (s *pubsubStats) Graft(p peer.ID, topic string) {
+	s.update(topic, func(t *topicCounters) { t.mesh[p] = struct{}{} })
+}
+
+func (s *pubsubStats) Prune(p peer.ID, topic string) {
+	s.update(topic, func(t *topicCounters) { delete(t.mesh, p) })
+}
+
+// published is called by the node, raw tracers only see remote messages.
+func (s *pubsubStats) published(topic string) {
+	s.update(topic, func(t *topicCounters) { t.published++ })
+}
+
+// DeliverMessage counts messages from peers only. pubsub does not trace the
+// messages the node delivers to itself to raw tracers, this does not rely on
+// it: they are counted as published only.
+func (s *pubsubStats) DeliverMessage(msg *pubsub.Message) {
+	if msg.ReceivedFrom == s.self {
+		return
+	}
+
+	s.update(msg.GetTopic(), func(t *topicCounters) { t.delivered++ })
+}
+
+func (s *pubsubStats) RejectMessage(msg *pubsub.Message, reason string) {
+	s.update(msg.GetTopic(), func(t *topicCounters) {
+		if reason == pubsub.RejectValidationThrottled {
+			t.throttled++
+		} else {
+			t.rejected++
+		}
+	})
+}
+
+func (s *pubsubStats) DuplicateMessage(msg *pubsub.Message) {
+	s.update(msg.GetTopic(), func(t *topicCounters) { t.duplicates++ })
+}
+
+func (s *pubsubStats) UndeliverableMessage(msg *pubsub.Message) {
+	s.update(msg.GetTopic(), func(t *topicCounters) { t.undeliverable++ })
+}
+
+func (s *pubsubStats) Leave(topic string) {
+	s.update(topic, func(t *topicCounters) { t.mesh = make(map[peer.ID]struct{}) })
+}
+
+func (s *pubsubStats) RemovePeer(p peer.ID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, t := range s.topics {
+		delete(t.mesh, p)
+	}
+}
+
+func (s *pubsubStats) AddPeer(peer.ID, protocol.ID)    {}
+func (s *pubsubStats) Join(string)                     {}
+func (s *pubsubStats) ValidateMessage(*pubsub.Message) {}
+func (s *pubsubStats) ThrottlePeer(peer.ID)            {}
+func (s *pubsubStats) RecvRPC(*pubsub.RPC)             {}
+func (s *pubsubStats) SendRPC(*pubsub.RPC, peer.ID)    {}
+func (s *pubsubStats) DropRPC(*pubsub.RPC, peer.ID)    {}
+
+// PubSubStats returns the counters of topic. Stats must be enabled with
+// PubSubConfig.Stats.
+func (p *node) PubSubStats(topic string) (TopicStats, error) {
+	if p.pubsubStats == nil {
+		return TopicStats{}, errPubSubStatsDisabled
+	}
+
+	return p.pubsubStats.stats(topic), nil
+}
@@ -0,0 +1,96 @@
+package peer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func TestPubSubStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	config := DefaultPubSubConfig()
+	config.Stats = true
+	config.TraceFile = filepath.Join(t.TempDir(), "trace.json")
+
+	p1 := MockNode(ctx, WithPubSub(config))
+	p2 := MockNode(ctx, WithPubSub(config))
+	defer p2.Close()
+
+	if err := mocknet.LinkAll(); err != nil {
+		t.Errorf("LinkAll returned error `%s`", err.Error())
+		return
+	}
+
+	if _, err := mocknet.ConnectPeers(p1.ID(), p2.ID()); err != nil {
+		t.Errorf("ConnectPeers returned error `%s`", err.Error())
+		return
+	}
+
+	received := make(chan struct{}, 1)
+	if _, err := p1.PubSubSubscribe("stats", func(*pubsub.Message) { received <- struct{}{} }, func(error) {}); err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	topic, _ := p2.PubSubTopic("stats")
+	for len(topic.ListPeers()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Error("peers never joined the topic")
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err := p2.PubSubPublish(ctx, "stats", []byte("hello")); err != nil {
+		t.Errorf("Publish returned error `%s`", err.Error())
+		return
+	}
+
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Error("message was not delivered")
+		return
+	}
+
+	if stats, err := p2.PubSubStats("stats"); err != nil || stats.Published != 1 {
+		t.Errorf("unexpected publisher stats %+v (%v)", stats, err)
+	}
+
+	// messages of the node to its own subscriptions are not counted as
+	// delivered
+	if err := p1.PubSubPublish(ctx, "stats", []byte("self")); err != nil {
+		t.Errorf("Publish returned error `%s`", err.Error())
+		return
+	}
+
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Error("message was not delivered")
+		return
+	}
+
+	if stats, err := p1.PubSubStats("stats"); err != nil || stats.Delivered != 1 || stats.Published != 1 || stats.Rejected != 0 {
+		t.Errorf("unexpected subscriber stats %+v (%v)", stats, err)
+	}
+
+	p1.Close()
+
+	if info, err := os.Stat(config.TraceFile); err != nil || info.Size() == 0 {
+		t.Errorf("trace file was not written: %v", err)
+	}
+
+	m := MockNode(ctx)
+	defer m.Close()
+	if _, err := m.PubSubStats("stats"); err == nil {
+		t.Error("expected an error when stats are disabled")
+	}
+}
@@ -0,0 +1,217 @@
+package peer
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// RelayReservation is a reservation the node holds on a relay, through which
+// it can be reached at Addr.
+type RelayReservation struct {
+	Relay peer.ID
+	Addr  multiaddr.Multiaddr
+}
+
+// HolePunchStats counts the hole punches of the node, as the initiator or
+// the receiver.
+type HolePunchStats struct {
+	Attempts  int
+	Successes int
+	Failures  int
+}
+
+// ReachabilityStatus is how the node can be reached by others.
+type ReachabilityStatus struct {
+	// Reachability is the one detected by AutoNAT, or forced by
+	// WithReachability, in which case Forced is set.
+	Reachability network.Reachability
+	Forced       bool
+	// ObservedAddrs are addresses of the node it does not listen on, learned
+	// from peers observing it or from NAT port mapping.
+	ObservedAddrs []multiaddr.Multiaddr
+	// ConfirmedAddrs are the addresses of the node other peers dialed back
+	// with AutoNAT v2.
+	ConfirmedAddrs []multiaddr.Multiaddr
+	// Relays are the active relay reservations, found by autorelay.
+	Relays    []RelayReservation
+	HolePunch HolePunchStats
+}
+
+// reachability holds what the node learns about its reachability from host
+// events.
+type reachability struct {
+	lock      sync.Mutex
+	status    network.Reachability
+	forced    bool
+	confirmed []multiaddr.Multiaddr
+	relays    []RelayReservation
+	holePunch HolePunchStats
+}
+
+func (r Reachability) status() network.Reachability {
+	switch r {
+	case ReachabilityPrivate:
+		return network.ReachabilityPrivate
+	case ReachabilityPublic:
+		return network.ReachabilityPublic
+	}
+
+	return network.ReachabilityUnknown
+}
+
+// Reachability returns how the node can be reached by others.
+func (p *node) Reachability() ReachabilityStatus {
+	p.reachability.lock.Lock()
+	status := ReachabilityStatus{
+		Reachability:   p.reachability.status,
+		Forced:         p.reachability.forced,
+		ConfirmedAddrs: append([]multiaddr.Multiaddr(nil), p.reachability.confirmed...),
+		Relays:         append([]RelayReservation(nil), p.reachability.relays...),
+		HolePunch:      p.reachability.holePunch,
+	}
+	p.reachability.lock.Unlock()
+
+	listen := make(map[string]struct{})
+	if addrs, err := p.host.Network().InterfaceListenAddresses(); err == nil {
+		for _, addr := range addrs {
+			listen[string(addr.Bytes())] = struct{}{}
+		}
+	}
+
+	for _, addr := range p.host.Addrs() {
+		if isRelayAddr(addr) {
+			continue
+		}
+
+		if _, ok := listen[string(addr.Bytes())]; !ok {
+			status.ObservedAddrs = append(status.ObservedAddrs, addr)
+		}
+	}
+
+	return status
+}
+
+func (p *node) setReachability(r network.Reachability) {
+	p.reachability.lock.Lock()
+	defer p.reachability.lock.Unlock()
+
+	p.reachability.status = r
+}
+
+func (p *node) setConfirmedAddrs(addrs []multiaddr.Multiaddr) {
+	p.reachability.lock.Lock()
+	defer p.reachability.lock.Unlock()
+
+	p.reachability.confirmed = addrs
+}
+
+// updateRelays sets the relay reservations found in addrs. It reports
+// whether they changed.
+func (p *node) updateRelays(addrs []multiaddr.Multiaddr) ([]RelayReservation, bool) {
+	relays := relayReservations(addrs)
+
+	p.reachability.lock.Lock()
+	defer p.reachability.lock.Unlock()
+
+	if sameRelays(p.reachability.relays, relays) {
+		return relays, false
+	}
+
+	p.reachability.relays = relays
+	return relays, true
+}
+
+func isRelayAddr(addr multiaddr.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT)
+	return err == nil
+}
+
+// relayReservations returns the reservations behind the circuit addresses of
+// addrs, one per relay.
+func relayReservations(addrs []multiaddr.Multiaddr) []RelayReservation {
+	var relays []RelayReservation
+	seen := make(map[peer.ID]struct{})
+	for _, addr := range addrs {
+		if !isRelayAddr(addr) {
+			continue
+		}
+
+		// /<relay transport>/p2p/<relay>/p2p-circuit
+		value, err := addr.ValueForProtocol(multiaddr.P_P2P)
+		if err != nil {
+			continue
+		}
+
+		id, err := peer.Decode(value)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			relays = append(relays, RelayReservation{Relay: id, Addr: addr})
+		}
+	}
+
+	return relays
+}
+
+func sameRelays(a, b []RelayReservation) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Relay != b[i].Relay {
+			return false
+		}
+	}
+
+	return true
+}
+
+// holePunchTracer counts hole punches and emits their outcome.
+type holePunchTracer struct {
+	node *node
+}
+
+func (t holePunchTracer) Trace(evt *holepunch.Event) {
+	p := t.node
+
+	switch e := evt.Evt.(type) {
+	case *holepunch.StartHolePunchEvt:
+		p.reachability.lock.Lock()
+		p.reachability.holePunch.Attempts++
+		p.reachability.lock.Unlock()
+	case *holepunch.EndHolePunchEvt:
+		p.reachability.lock.Lock()
+		if e.Success {
+			p.reachability.holePunch.Successes++
+		} else {
+			p.reachability.holePunch.Failures++
+		}
+		p.reachability.lock.Unlock()
+
+		if e.Success {
+			p.events.emit(Event{Type: EventHolePunchSucceeded, Peer: evt.Remote})
+		} else {
+			p.events.emit(Event{Type: EventHolePunchFailed, Peer: evt.Remote})
+		}
+	}
+}
+
+// traceHolePunching makes hole punching, if enabled by other options, report
+// to the node.
+func (p *node) traceHolePunching() libp2p.Option {
+	return func(cfg *libp2p.Config) error {
+		if cfg.EnableHolePunching {
+			cfg.HolePunchingOptions = append(cfg.HolePunchingOptions, holepunch.WithTracer(holePunchTracer{node: p}))
+		}
+		return nil
+	}
+}
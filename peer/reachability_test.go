@@ -0,0 +1,149 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestReachability(t *testing.T) {
+	ctx := context.Background()
+
+	p, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithReachability(ReachabilityPrivate))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p.Close()
+
+	status := p.Reachability()
+	if status.Reachability != network.ReachabilityPrivate || !status.Forced {
+		t.Errorf("expected forced private reachability, got %+v", status)
+	}
+
+	if len(status.ConfirmedAddrs) != 0 || len(status.Relays) != 0 {
+		t.Errorf("unexpected addresses %+v", status)
+	}
+
+	events := p.Events(ctx)
+
+	remote := MockNode(ctx)
+	defer remote.Close()
+
+	tracer := holePunchTracer{node: p.(*node)}
+	tracer.Trace(&holepunch.Event{Remote: remote.ID(), Evt: &holepunch.StartHolePunchEvt{}})
+	tracer.Trace(&holepunch.Event{Remote: remote.ID(), Evt: &holepunch.EndHolePunchEvt{Success: true}})
+	tracer.Trace(&holepunch.Event{Remote: remote.ID(), Evt: &holepunch.StartHolePunchEvt{}})
+	tracer.Trace(&holepunch.Event{Remote: remote.ID(), Evt: &holepunch.EndHolePunchEvt{}})
+
+	if hp := p.Reachability().HolePunch; hp != (HolePunchStats{Attempts: 2, Successes: 1, Failures: 1}) {
+		t.Errorf("unexpected hole punch stats %+v", hp)
+	}
+
+	var got []EventType
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case e := <-events:
+			if e.Type == EventHolePunchSucceeded || e.Type == EventHolePunchFailed {
+				if e.Peer != remote.ID() {
+					t.Errorf("unexpected peer %s", e.Peer)
+				}
+				got = append(got, e.Type)
+			}
+		case <-timeout:
+			t.Errorf("missing hole punch events, got %v", got)
+			return
+		}
+	}
+
+	if got[0] != EventHolePunchSucceeded || got[1] != EventHolePunchFailed {
+		t.Errorf("unexpected hole punch events %v", got)
+	}
+}
+
+func TestConfirmedAddrs(t *testing.T) {
+	ctx := context.Background()
+
+	p, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p.Close()
+
+	var autonat bool
+	for _, pid := range p.Peer().Mux().Protocols() {
+		autonat = autonat || pid == autonatv2.DialProtocol
+	}
+
+	if !autonat {
+		t.Error("autonat v2 is not enabled")
+	}
+
+	events := p.Events(ctx)
+
+	emitter, err := p.Peer().EventBus().Emitter(&event.EvtHostReachableAddrsChanged{})
+	if err != nil {
+		t.Errorf("Emitter returned error `%s`", err.Error())
+		return
+	}
+	defer emitter.Close()
+
+	confirmed := multiaddr.StringCast("/ip4/1.2.3.4/tcp/4001")
+	unreachable := multiaddr.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")
+	if err = emitter.Emit(event.EvtHostReachableAddrsChanged{Reachable: []multiaddr.Multiaddr{confirmed}, Unreachable: []multiaddr.Multiaddr{unreachable}}); err != nil {
+		t.Errorf("Emit returned error `%s`", err.Error())
+		return
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type != EventConfirmedAddrsChanged {
+				continue
+			}
+
+			if len(e.Addrs) != 1 || !e.Addrs[0].Equal(confirmed) {
+				t.Errorf("unexpected confirmed addresses %v", e.Addrs)
+			}
+
+			if addrs := p.Reachability().ConfirmedAddrs; len(addrs) != 1 || !addrs[0].Equal(confirmed) {
+				t.Errorf("unexpected confirmed addresses %v", addrs)
+			}
+			return
+		case <-timeout:
+			t.Error("missing confirmed addresses event")
+			return
+		}
+	}
+}
+
+func TestRelayReservations(t *testing.T) {
+	relay := MockNode(context.Background())
+	defer relay.Close()
+
+	direct := multiaddr.StringCast("/ip4/1.2.3.4/tcp/4001")
+	relayed := multiaddr.StringCast("/ip4/5.6.7.8/tcp/4001/p2p/" + relay.ID().String() + "/p2p-circuit")
+	relayedQuic := multiaddr.StringCast("/ip4/5.6.7.8/udp/4001/quic-v1/p2p/" + relay.ID().String() + "/p2p-circuit")
+
+	relays := relayReservations([]multiaddr.Multiaddr{direct, relayed, relayedQuic})
+	if len(relays) != 1 || relays[0].Relay != relay.ID() || !relays[0].Addr.Equal(relayed) {
+		t.Errorf("unexpected reservations %v", relays)
+	}
+
+	if !sameRelays(relays, []RelayReservation{{Relay: relay.ID()}}) || sameRelays(relays, nil) {
+		t.Error("sameRelays compares the wrong fields")
+	}
+
+	if len(relayReservations([]multiaddr.Multiaddr{direct})) != 0 {
+		t.Error("expected no reservation without circuit addresses")
+	}
+}
@@ -0,0 +1,327 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	record "github.com/libp2p/go-libp2p-record"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// DefaultRecordTTL is how long records published with PutValue are valid,
+// unless set with RecordTTL.
+var DefaultRecordTTL = 24 * time.Hour
+
+// recordSignaturePrefix separates record signatures from other signatures of
+// the node key.
+const recordSignaturePrefix = "taubyte-record:"
+
+// Record is a signed value of the DHT.
+type Record struct {
+	Key       string
+	Value     []byte
+	Seq       uint64
+	Publisher peer.ID
+	Expires   time.Time
+}
+
+// Expired reports whether r is expired at now.
+func (r *Record) Expired(now time.Time) bool {
+	return !now.Before(r.Expires)
+}
+
+// signedRecord is a Record as stored in the DHT.
+type signedRecord struct {
+	Value     []byte
+	Seq       uint64
+	Expires   int64
+	PublicKey []byte
+	Signature []byte
+}
+
+type recordPayload struct {
+	Key     string
+	Value   []byte
+	Seq     uint64
+	Expires int64
+}
+
+func (s *signedRecord) payload(key string) ([]byte, error) {
+	data, err := cbor.Marshal(recordPayload{Key: key, Value: s.Value, Seq: s.Seq, Expires: s.Expires})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(recordSignaturePrefix), data...), nil
+}
+
+// openRecord verifies the signature of value and returns its record. Expiry
+// is not checked.
+func openRecord(key string, value []byte) (*Record, error) {
+	var s signedRecord
+	if err := cbor.Unmarshal(value, &s); err != nil {
+		return nil, fmt.Errorf("decoding record failed with: %w", err)
+	}
+
+	pub, err := crypto.UnmarshalPublicKey(s.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding record public key failed with: %w", err)
+	}
+
+	publisher, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := s.payload(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := pub.Verify(payload, s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("verifying record signature failed with: %w", err)
+	}
+	if !ok {
+		return nil, errors.New("invalid record signature")
+	}
+
+	return &Record{
+		Key:       key,
+		Value:     s.Value,
+		Seq:       s.Seq,
+		Publisher: publisher,
+		Expires:   time.Unix(0, s.Expires),
+	}, nil
+}
+
+// RecordKey returns the key of the record name of publisher in the namespace
+// ns, /<ns>/<publisher>/<name>. It is the only key a RecordValidator without
+// Authorize accepts from publisher.
+func RecordKey(ns string, publisher peer.ID, name string) string {
+	return "/" + ns + "/" + publisher.String() + "/" + name
+}
+
+// authorizeOwner accepts the keys of the form of RecordKey for publisher.
+func authorizeOwner(key string, publisher peer.ID) error {
+	parts := strings.SplitN(strings.TrimPrefix(key, "/"), "/", 3)
+	if len(parts) < 3 || parts[2] == "" || parts[1] != publisher.String() {
+		return errors.New("key is not under the publisher id")
+	}
+
+	return nil
+}
+
+// RecordValidator validates the signed records of a namespace: they must be
+// signed by an authorized publisher and not expired. Of several valid records,
+// the one with the highest sequence number is selected, then the one expiring
+// last.
+type RecordValidator struct {
+	// Authorize, if set, decides whether publisher may publish under key.
+	// Otherwise publishers may only publish under their own keys, see
+	// RecordKey.
+	Authorize func(key string, publisher peer.ID) error
+
+	now func() time.Time
+}
+
+var _ record.Validator = RecordValidator{}
+
+func (v RecordValidator) time() time.Time {
+	if v.now != nil {
+		return v.now()
+	}
+
+	return time.Now()
+}
+
+// open returns the record of value if it is valid.
+func (v RecordValidator) open(key string, value []byte) (*Record, error) {
+	r, err := openRecord(key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Expired(v.time()) {
+		return nil, errors.New("record is expired")
+	}
+
+	authorize := v.Authorize
+	if authorize == nil {
+		authorize = authorizeOwner
+	}
+
+	if err = authorize(key, r.Publisher); err != nil {
+		return nil, fmt.Errorf("publisher %s is not authorized: %w", r.Publisher, err)
+	}
+
+	return r, nil
+}
+
+func (v RecordValidator) Validate(key string, value []byte) error {
+	_, err := v.open(key, value)
+	return err
+}
+
+func (v RecordValidator) Select(key string, values [][]byte) (int, error) {
+	best := -1
+	var bestRecord *Record
+	for i, value := range values {
+		r, err := v.open(key, value)
+		if err != nil {
+			continue
+		}
+
+		if bestRecord == nil || r.Seq > bestRecord.Seq || (r.Seq == bestRecord.Seq && r.Expires.After(bestRecord.Expires)) {
+			best, bestRecord = i, r
+		}
+	}
+
+	if best < 0 {
+		return 0, errors.New("no valid record")
+	}
+
+	return best, nil
+}
+
+// WithRecordNamespace registers the namespace ns of DHT keys, /<ns>/..., for
+// records published with PutValue. A nil validator is a RecordValidator.
+// Nodes storing records need the namespace registered too. Custom namespaces
+// need a DHT protocol prefix other than /ipfs, see WithDHT.
+func WithRecordNamespace(ns string, validator record.Validator) Option {
+	return func(o *options) error {
+		if ns == "" || strings.Contains(ns, "/") {
+			return fmt.Errorf("invalid record namespace `%s`", ns)
+		}
+
+		if validator == nil {
+			validator = RecordValidator{}
+		}
+
+		if o.recordNamespaces == nil {
+			o.recordNamespaces = make(map[string]record.Validator)
+		}
+
+		o.recordNamespaces[ns] = validator
+		return nil
+	}
+}
+
+type recordOptions struct {
+	ttl time.Duration
+	seq uint64
+}
+
+// RecordOption configures a record published with PutValue.
+type RecordOption func(o *recordOptions)
+
+// RecordTTL sets how long the record is valid. Defaults to DefaultRecordTTL.
+func RecordTTL(ttl time.Duration) RecordOption {
+	return func(o *recordOptions) {
+		o.ttl = ttl
+	}
+}
+
+// RecordSeq sets the sequence number of the record. Defaults to the current
+// time in nanoseconds, so later records win.
+func RecordSeq(seq uint64) RecordOption {
+	return func(o *recordOptions) {
+		o.seq = seq
+	}
+}
+
+// PutValue signs value with the node key and publishes it in the DHT under
+// key, of the form /<namespace>/<peer id>/<name> unless the namespace
+// authorizes other keys, see RecordKey.
+func (p *node) PutValue(ctx context.Context, key string, value []byte, opts ...RecordOption) error {
+	o := recordOptions{ttl: DefaultRecordTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.ttl <= 0 {
+		return errors.New("record ttl must be positive")
+	}
+
+	now := time.Now()
+	if o.seq == 0 {
+		o.seq = uint64(now.UnixNano())
+	}
+
+	pub, err := crypto.MarshalPublicKey(p.key.GetPublic())
+	if err != nil {
+		return err
+	}
+
+	s := signedRecord{
+		Value:     value,
+		Seq:       o.seq,
+		Expires:   now.Add(o.ttl).UnixNano(),
+		PublicKey: pub,
+	}
+
+	payload, err := s.payload(key)
+	if err != nil {
+		return err
+	}
+
+	if s.Signature, err = p.key.Sign(payload); err != nil {
+		return fmt.Errorf("signing record failed with: %w", err)
+	}
+
+	data, err := cbor.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err = p.dht.PutValue(ctx, key, data); err != nil {
+		return fmt.Errorf("putting `%s` failed with: %w", key, err)
+	}
+
+	return nil
+}
+
+// GetValue returns the best record found in the DHT under key.
+func (p *node) GetValue(ctx context.Context, key string) (*Record, error) {
+	data, err := p.dht.GetValue(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("getting `%s` failed with: %w", key, err)
+	}
+
+	return openRecord(key, data)
+}
+
+// SearchValue returns the records found in the DHT under key, each better
+// than the previous one. The channel is closed when the search ends or ctx is
+// done.
+func (p *node) SearchValue(ctx context.Context, key string) (<-chan *Record, error) {
+	values, err := p.dht.SearchValue(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("searching `%s` failed with: %w", key, err)
+	}
+
+	records := make(chan *Record)
+	go func() {
+		defer close(records)
+		for data := range values {
+			r, err := openRecord(key, data)
+			if err != nil {
+				logger.Debugf("ignoring record of `%s`: %s", key, err)
+				continue
+			}
+
+			select {
+			case records <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return records, nil
+}
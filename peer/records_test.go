@@ -0,0 +1,169 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	helpers "github.com/taubyte/p2p/helpers"
+)
+
+func TestRecords(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	newNode := func() Node {
+		p, err := NewNode(ctx,
+			WithListen("/ip4/127.0.0.1/tcp/0"),
+			WithBootstrap(StandAlone()),
+			WithDHT(helpers.DHTConfig{Mode: helpers.DHTModeServer, ProtocolPrefix: "/test", Topology: helpers.DHTLANOnly}),
+			WithRecordNamespace("manifest", nil),
+		)
+		if err != nil {
+			t.Fatalf("NewNode returned error `%s`", err.Error())
+		}
+		return p
+	}
+
+	p1 := newNode()
+	defer p1.Close()
+
+	p2 := newNode()
+	defer p2.Close()
+
+	if err := p2.Peer().Connect(ctx, peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	key := RecordKey("manifest", p1.ID(), "hello")
+
+	// wait for the peers to be in each other's routing table
+	var err error
+	for i := 0; i < 50; i++ {
+		if err = p1.PutValue(ctx, key, []byte("v1"), RecordSeq(1)); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("PutValue returned error `%s`", err.Error())
+		return
+	}
+
+	r, err := p2.GetValue(ctx, key)
+	if err != nil {
+		t.Errorf("GetValue returned error `%s`", err.Error())
+		return
+	}
+
+	if string(r.Value) != "v1" || r.Seq != 1 || r.Publisher != p1.ID() || r.Expired(time.Now()) {
+		t.Errorf("unexpected record %+v", r)
+	}
+
+	if err = p1.PutValue(ctx, key, []byte("v2"), RecordSeq(2)); err != nil {
+		t.Errorf("PutValue returned error `%s`", err.Error())
+		return
+	}
+
+	if err = p1.PutValue(ctx, key, []byte("v0"), RecordSeq(1)); err == nil {
+		t.Error("expected an error replacing a record with an older one")
+	}
+
+	records, err := p2.SearchValue(ctx, key)
+	if err != nil {
+		t.Errorf("SearchValue returned error `%s`", err.Error())
+		return
+	}
+
+	var last *Record
+	for r := range records {
+		last = r
+	}
+
+	if last == nil || string(last.Value) != "v2" {
+		t.Errorf("expected the search to end with v2, got %+v", last)
+	}
+
+	if err = p1.PutValue(ctx, RecordKey("unknown", p1.ID(), "hello"), []byte("v1")); err == nil {
+		t.Error("expected an error for an unregistered namespace")
+	}
+
+	// publishers can not take over keys of other publishers
+	if err = p2.PutValue(ctx, key, []byte("v3"), RecordSeq(3)); err == nil {
+		t.Error("expected an error publishing under the key of another peer")
+	}
+}
+
+func TestRecordValidator(t *testing.T) {
+	p := MockNode(context.Background())
+	defer p.Close()
+
+	sign := func(key string, value string, seq uint64, ttl time.Duration) []byte {
+		s := signedRecord{Value: []byte(value), Seq: seq, Expires: time.Now().Add(ttl).UnixNano()}
+		s.PublicKey, _ = crypto.MarshalPublicKey(p.(*node).key.GetPublic())
+		payload, _ := s.payload(key)
+		s.Signature, _ = p.(*node).key.Sign(payload)
+		data, _ := cbor.Marshal(s)
+		return data
+	}
+
+	key := RecordKey("ns", p.ID(), "a")
+
+	v := RecordValidator{}
+	valid := sign(key, "a", 1, time.Minute)
+	if err := v.Validate(key, valid); err != nil {
+		t.Errorf("Validate returned error `%s`", err.Error())
+	}
+
+	if err := v.Validate(RecordKey("ns", p.ID(), "b"), valid); err == nil {
+		t.Error("expected an error for a record signed for another key")
+	}
+
+	if err := v.Validate(key, sign(key, "a", 1, -time.Minute)); err == nil {
+		t.Error("expected an error for an expired record")
+	}
+
+	tampered := sign(key, "a", 1, time.Minute)
+	tampered[len(tampered)-1] ^= 0xff
+	if err := v.Validate(key, tampered); err == nil {
+		t.Error("expected an error for a tampered record")
+	}
+
+	// keys must be under the publisher id without Authorize
+	otherKey, _, _ := crypto.GenerateEd25519Key(nil)
+	other, _ := peer.IDFromPrivateKey(otherKey)
+	for _, k := range []string{"/ns/a", RecordKey("ns", other, "a"), "/ns/" + p.ID().String(), "/ns/" + p.ID().String() + "/"} {
+		if err := v.Validate(k, sign(k, "a", 1, time.Minute)); err == nil {
+			t.Errorf("expected an error for key `%s`", k)
+		}
+	}
+
+	i, err := v.Select(key, [][]byte{
+		sign(key, "old", 1, time.Hour),
+		sign(key, "new", 2, time.Minute),
+		sign(key, "new, expiring first", 2, time.Second),
+		sign(key, "newest, expired", 3, -time.Second),
+	})
+	if err != nil || i != 1 {
+		t.Errorf("expected record 1 to be selected, got %d, %v", i, err)
+	}
+
+	if _, err = v.Select(key, [][]byte{sign(key, "expired", 1, -time.Second)}); err == nil {
+		t.Error("expected an error when all records are expired")
+	}
+
+	v.Authorize = func(string, peer.ID) error { return errors.New("denied") }
+	if err := v.Validate(key, valid); err == nil {
+		t.Error("expected an error for an unauthorized publisher")
+	}
+
+	v.Authorize = func(string, peer.ID) error { return nil }
+	if err := v.Validate("/ns/a", sign("/ns/a", "a", 1, time.Minute)); err != nil {
+		t.Errorf("Validate returned error `%s`", err.Error())
+	}
+}
@@ -0,0 +1,138 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+)
+
+// relayTag protects static relays from the gater.
+const relayTag = "relay"
+
+// RelayServiceConfig configures the circuit relay service of a node. Zero
+// values keep the libp2p defaults, see relayv2.DefaultResources.
+type RelayServiceConfig struct {
+	// ReservationTTL is how long a reservation lasts before it is refreshed.
+	ReservationTTL time.Duration
+	// MaxReservations bounds the active reservations, all peers together.
+	MaxReservations int
+	// MaxReservationsPerPeer bounds the reservations of a single peer.
+	MaxReservationsPerPeer int
+	// MaxReservationsPerIP bounds the reservations from a single IP address.
+	MaxReservationsPerIP int
+	// MaxCircuits bounds the open circuits of each peer.
+	MaxCircuits int
+	// BufferSize is the size of the buffers of relayed connections.
+	BufferSize int
+	// CircuitDuration bounds how long a relayed connection stays open.
+	CircuitDuration time.Duration
+	// CircuitData bounds the bytes relayed in each direction of a relayed
+	// connection.
+	CircuitData int64
+	// Unlimited lifts the duration and data limits of relayed connections.
+	Unlimited bool
+}
+
+// Validate checks the values of c.
+func (c RelayServiceConfig) Validate() error {
+	if c.ReservationTTL < 0 || c.CircuitDuration < 0 || c.CircuitData < 0 {
+		return errors.New("relay service limits can not be negative")
+	}
+
+	if c.MaxReservations < 0 || c.MaxReservationsPerPeer < 0 || c.MaxReservationsPerIP < 0 || c.MaxCircuits < 0 || c.BufferSize < 0 {
+		return errors.New("relay service limits can not be negative")
+	}
+
+	if c.Unlimited && (c.CircuitDuration != 0 || c.CircuitData != 0) {
+		return errors.New("unlimited relay service can not set circuit limits")
+	}
+
+	return nil
+}
+
+// resources returns the relay resources c results in.
+func (c RelayServiceConfig) resources() relayv2.Resources {
+	r := relayv2.DefaultResources()
+
+	if c.ReservationTTL != 0 {
+		r.ReservationTTL = c.ReservationTTL
+	}
+	if c.MaxReservations != 0 {
+		r.MaxReservations = c.MaxReservations
+	}
+	if c.MaxReservationsPerPeer != 0 {
+		r.MaxReservationsPerPeer = c.MaxReservationsPerPeer
+	}
+	if c.MaxReservationsPerIP != 0 {
+		r.MaxReservationsPerIP = c.MaxReservationsPerIP
+	}
+	if c.MaxCircuits != 0 {
+		r.MaxCircuits = c.MaxCircuits
+	}
+	if c.BufferSize != 0 {
+		r.BufferSize = c.BufferSize
+	}
+
+	if c.Unlimited {
+		r.Limit = nil
+		return r
+	}
+
+	if c.CircuitDuration != 0 {
+		r.Limit.Duration = c.CircuitDuration
+	}
+	if c.CircuitData != 0 {
+		r.Limit.Data = c.CircuitData
+	}
+
+	return r
+}
+
+// WithRelayService runs the circuit relay service with the limits of config.
+// Full and public profiles run it with the defaults otherwise. The service
+// only starts once the node finds itself publicly reachable.
+func WithRelayService(config RelayServiceConfig) Option {
+	return func(o *options) error {
+		if err := config.Validate(); err != nil {
+			return err
+		}
+
+		o.relayService = &config
+		return nil
+	}
+}
+
+// WithStaticRelays makes the node reserve slots on the given relays instead
+// of looking for relays in the DHT. Reservations are made once the node finds
+// itself privately reachable, see WithReachability.
+func WithStaticRelays(relays ...peer.AddrInfo) Option {
+	return func(o *options) error {
+		for _, relay := range relays {
+			if relay.ID == "" || len(relay.Addrs) == 0 {
+				return fmt.Errorf("static relay `%s` needs an id and addresses", relay)
+			}
+		}
+
+		o.staticRelays = append(o.staticRelays, relays...)
+		return nil
+	}
+}
+
+// relayOptions returns the libp2p options of the relay configuration. They
+// override the relay service of the profile and the DHT relay source.
+func (o *options) relayOptions() []libp2p.Option {
+	var opts []libp2p.Option
+	if o.relayService != nil {
+		opts = append(opts, libp2p.EnableRelayService(relayv2.WithResources(o.relayService.resources())))
+	}
+
+	if len(o.staticRelays) > 0 {
+		opts = append(opts, libp2p.EnableAutoRelayWithStaticRelays(o.staticRelays))
+	}
+
+	return opts
+}
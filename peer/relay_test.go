@@ -0,0 +1,111 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestStaticRelays(t *testing.T) {
+	ctx := context.Background()
+
+	relay, err := NewNode(ctx,
+		WithListen("/ip4/127.0.0.1/tcp/0"),
+		WithBootstrap(StandAlone()),
+		WithProfile(ProfilePublic),
+		WithReachability(ReachabilityPublic),
+		WithRelayService(RelayServiceConfig{MaxReservations: 4, CircuitDuration: time.Minute}),
+	)
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer relay.Close()
+
+	relayInfo := peer.AddrInfo{ID: relay.ID(), Addrs: relay.Peer().Addrs()}
+	private, err := NewNode(ctx,
+		WithListen("/ip4/127.0.0.1/tcp/0"),
+		WithBootstrap(StandAlone()),
+		WithReachability(ReachabilityPrivate),
+		WithStaticRelays(relayInfo),
+	)
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer private.Close()
+
+	if !private.Gater().IsProtected(relay.ID()) {
+		t.Error("static relay is not protected")
+	}
+
+	// autorelay protects the relays it holds a reservation on. Loopback
+	// relays are not added to the node addresses.
+	reserved := false
+	for start := time.Now(); time.Since(start) < 10*time.Second && !reserved; time.Sleep(100 * time.Millisecond) {
+		reserved = private.Peer().ConnManager().IsProtected(relay.ID(), "autorelay")
+	}
+
+	if !reserved {
+		t.Error("no reservation on the static relay")
+		return
+	}
+
+	dialer, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer dialer.Close()
+
+	circuit := relayInfo.Addrs[0].Encapsulate(multiaddr.StringCast("/p2p/" + relay.ID().String() + "/p2p-circuit"))
+	if err = dialer.Peer().Connect(ctx, peer.AddrInfo{ID: private.ID(), Addrs: []multiaddr.Multiaddr{circuit}}); err != nil {
+		t.Errorf("Connect through the relay returned error `%s`", err.Error())
+		return
+	}
+
+	conns := dialer.Peer().Network().ConnsToPeer(private.ID())
+	if len(conns) != 1 || !isRelayAddr(conns[0].RemoteMultiaddr()) {
+		t.Errorf("expected a relayed connection, got %v", conns)
+		return
+	}
+
+	// circuits of a limited relay service are limited
+	if !conns[0].Stat().Limited {
+		t.Error("expected a limited relayed connection")
+	}
+}
+
+func TestRelayServiceConfig(t *testing.T) {
+	r := RelayServiceConfig{MaxReservations: 4, MaxCircuits: 2, CircuitData: 1 << 20}.resources()
+	defaults := relayv2.DefaultResources()
+	if r.MaxReservations != 4 || r.MaxCircuits != 2 || r.Limit.Data != 1<<20 {
+		t.Errorf("unexpected relay resources %+v", r)
+	}
+
+	if r.ReservationTTL != defaults.ReservationTTL || r.Limit.Duration != defaults.Limit.Duration || r.BufferSize != defaults.BufferSize {
+		t.Errorf("expected default relay resources, got %+v", r)
+	}
+
+	if r = (RelayServiceConfig{Unlimited: true}).resources(); r.Limit != nil {
+		t.Errorf("expected no circuit limit, got %+v", r.Limit)
+	}
+
+	for _, config := range []RelayServiceConfig{
+		{MaxCircuits: -1},
+		{ReservationTTL: -time.Second},
+		{Unlimited: true, CircuitData: 1},
+	} {
+		if _, err := NewNode(context.Background(), WithRelayService(config)); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+
+	if _, err := NewNode(context.Background(), WithStaticRelays(peer.AddrInfo{})); err == nil {
+		t.Error("expected an error for a static relay without id")
+	}
+}
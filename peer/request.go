@@ -0,0 +1,297 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/taubyte/p2p/streams/command/framer"
+	"github.com/taubyte/p2p/streams/packer"
+	"github.com/taubyte/utils/id"
+)
+
+// ReplyProtocol is the stream protocol responders use to send replies back to
+// the requesting node.
+const ReplyProtocol = protocol.ID("/taubyte/pubsub/reply/1.0.0")
+
+var (
+	replyMagic   = packer.Magic{0x01, 0xee}
+	replyVersion = packer.Version(0x01)
+
+	// DefaultRequestTimeout is how long PubSubRequest gathers replies when
+	// no RequestTimeout is given.
+	DefaultRequestTimeout = 5 * time.Second
+
+	// MaxRespondTimeout caps how long PubSubRespond handlers run, whatever
+	// the timeout of the request.
+	MaxRespondTimeout = DefaultRequestTimeout
+)
+
+// requestEnvelope carries a relative timeout, the clocks of the requester and
+// the responders are not assumed to agree.
+type requestEnvelope struct {
+	ID      string `cbor:"1,keyasint"`
+	Timeout int64  `cbor:"2,keyasint"`
+	Payload []byte `cbor:"3,keyasint"`
+}
+
+type replyEnvelope struct {
+	ID      string `cbor:"1,keyasint"`
+	Payload []byte `cbor:"2,keyasint"`
+	Error   string `cbor:"3,keyasint,omitempty"`
+}
+
+// Reply is the answer of a peer to a PubSubRequest.
+type Reply struct {
+	From    peer.ID
+	Payload []byte
+	Err     error
+}
+
+// RequestHandler answers a request received on a topic. A returned error is
+// sent back to the requester instead of a payload.
+type RequestHandler func(ctx context.Context, from peer.ID, payload []byte) ([]byte, error)
+
+type requestOptions struct {
+	quorum     int
+	timeout    time.Duration
+	duplicates bool
+}
+
+// RequestOption configures a PubSubRequest.
+type RequestOption func(o *requestOptions) error
+
+// RequestQuorum makes the request return as soon as n replies are gathered.
+// By default, replies are gathered until the timeout.
+func RequestQuorum(n int) RequestOption {
+	return func(o *requestOptions) error {
+		if n < 0 {
+			return errors.New("quorum can not be negative")
+		}
+
+		o.quorum = n
+		return nil
+	}
+}
+
+// RequestTimeout sets how long replies are gathered for.
+func RequestTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// RequestDuplicates sets whether more than one reply per peer is kept.
+// Defaults to false.
+func RequestDuplicates(allow bool) RequestOption {
+	return func(o *requestOptions) error {
+		o.duplicates = allow
+		return nil
+	}
+}
+
+type pendingRequest struct {
+	lock    sync.Mutex
+	seen    map[peer.ID]struct{}
+	replies chan *Reply
+	opts    requestOptions
+}
+
+// PubSubRequest publishes payload on topic and gathers the replies of the
+// peers responding with PubSubRespond. It returns when the quorum is reached,
+// or when the timeout or ctx expire; in which case the replies gathered so far
+// are returned along with an error if a quorum was set.
+func (p *node) PubSubRequest(ctx context.Context, topic string, payload []byte, opts ...RequestOption) ([]*Reply, error) {
+	if p.isClosed() {
+		return nil, errorClosed
+	}
+
+	o := requestOptions{timeout: DefaultRequestTimeout}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	p.requestsOnce.Do(func() {
+		p.SetStreamHandler(ReplyProtocol, p.handleReply)
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	req := requestEnvelope{
+		ID:      id.Generate(p.id.String(), topic),
+		Timeout: int64(o.timeout),
+		Payload: payload,
+	}
+
+	data, err := cbor.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &pendingRequest{
+		seen:    make(map[peer.ID]struct{}),
+		replies: make(chan *Reply, 16),
+		opts:    o,
+	}
+
+	p.requests.Store(req.ID, pending)
+	defer p.requests.Delete(req.ID)
+
+	if err = p.PubSubPublish(ctx, topic, data); err != nil {
+		return nil, err
+	}
+
+	replies := make([]*Reply, 0, o.quorum)
+	for {
+		select {
+		case r := <-pending.replies:
+			replies = append(replies, r)
+			if o.quorum > 0 && len(replies) >= o.quorum {
+				return replies, nil
+			}
+		case <-ctx.Done():
+			if o.quorum > 0 {
+				return replies, fmt.Errorf("got %d of %d replies: %w", len(replies), o.quorum, ctx.Err())
+			}
+			return replies, nil
+		}
+	}
+}
+
+func (p *node) handleReply(s network.Stream) {
+	defer s.Close()
+
+	s.SetReadDeadline(time.Now().Add(DefaultRequestTimeout))
+
+	var reply replyEnvelope
+	if err := framer.Read(replyMagic, replyVersion, s, &reply); err != nil {
+		logger.Debugf("reading reply from %s failed with: %s", s.Conn().RemotePeer(), err)
+		s.Reset()
+		return
+	}
+
+	v, ok := p.requests.Load(reply.ID)
+	if !ok {
+		return
+	}
+	pending := v.(*pendingRequest)
+
+	from := s.Conn().RemotePeer()
+	r := &Reply{From: from, Payload: reply.Payload}
+	if reply.Error != "" {
+		r.Err = errors.New(reply.Error)
+	}
+
+	pending.lock.Lock()
+	defer pending.lock.Unlock()
+
+	if !pending.opts.duplicates {
+		if _, ok := pending.seen[from]; ok {
+			return
+		}
+		pending.seen[from] = struct{}{}
+	}
+
+	select {
+	case pending.replies <- r:
+	default:
+		logger.Warnf("dropping reply from %s, requester is too slow", from)
+	}
+}
+
+// PubSubRespond answers requests published on topic with PubSubRequest using
+// handler. Requests sent by the node itself are ignored. The handler context
+// expires with the request, after MaxRespondTimeout at most; no reply is sent
+// once it expired.
+func (p *node) PubSubRespond(topic string, handler RequestHandler) (*Subscription, error) {
+	return p.PubSubSubscribe(
+		topic,
+		func(msg *pubsub.Message) {
+			from := msg.GetFrom()
+			if from == p.id {
+				return
+			}
+
+			var req requestEnvelope
+			if err := cbor.Unmarshal(msg.Data, &req); err != nil || req.ID == "" {
+				logger.Debugf("ignoring malformed request on `%s` from %s", topic, from)
+				return
+			}
+
+			go p.respond(from, req, handler)
+		},
+		func(err error) {
+			logger.Debugf("responder on `%s` stopped: %s", topic, err)
+		},
+	)
+}
+
+// respondTimeout is how long a request may be handled locally.
+func (req requestEnvelope) respondTimeout() time.Duration {
+	timeout := time.Duration(req.Timeout)
+	if timeout <= 0 || timeout > MaxRespondTimeout {
+		return MaxRespondTimeout
+	}
+
+	return timeout
+}
+
+func (p *node) respond(to peer.ID, req requestEnvelope, handler RequestHandler) {
+	ctx, cancel := context.WithTimeout(p.ctx, req.respondTimeout())
+	defer cancel()
+
+	done := make(chan replyEnvelope, 1)
+	go func() {
+		reply := replyEnvelope{ID: req.ID}
+		payload, err := handler(ctx, to, req.Payload)
+		if err != nil {
+			reply.Error = err.Error()
+		} else {
+			reply.Payload = payload
+		}
+		done <- reply
+	}()
+
+	// handlers ignoring their context are not waited for
+	var reply replyEnvelope
+	select {
+	case reply = <-done:
+	case <-ctx.Done():
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	s, err := p.host.NewStream(ctx, to, ReplyProtocol)
+	if err != nil {
+		logger.Debugf("opening reply stream to %s failed with: %s", to, err)
+		return
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetWriteDeadline(deadline)
+	}
+
+	if err = framer.Send(replyMagic, replyVersion, s, reply); err != nil {
+		logger.Debugf("sending reply to %s failed with: %s", to, err)
+		s.Reset()
+	}
+}
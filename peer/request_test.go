@@ -0,0 +1,190 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	peercore "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestPubSubRequest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nodes := make([]Node, 3)
+	for i := range nodes {
+		n, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+		if err != nil {
+			t.Errorf("NewNode returned error `%s`", err.Error())
+			return
+		}
+		defer n.Close()
+		nodes[i] = n
+	}
+
+	requester := nodes[0]
+	for _, n := range nodes[1:] {
+		err := n.Peer().Connect(ctx, peercore.AddrInfo{ID: requester.ID(), Addrs: requester.Peer().Addrs()})
+		if err != nil {
+			t.Errorf("Connect returned error `%s`", err.Error())
+			return
+		}
+
+		responder := n
+		_, err = n.PubSubRespond("presence", func(_ context.Context, from peercore.ID, payload []byte) ([]byte, error) {
+			if from != requester.ID() {
+				return nil, errors.New("unexpected requester")
+			}
+			return append(payload, []byte(responder.ID())...), nil
+		})
+		if err != nil {
+			t.Errorf("Respond returned error `%s`", err.Error())
+			return
+		}
+	}
+
+	topic, err := requester.PubSubTopic("presence")
+	if err != nil {
+		t.Errorf("Topic returned error `%s`", err.Error())
+		return
+	}
+
+	for len(topic.ListPeers()) < 2 {
+		select {
+		case <-ctx.Done():
+			t.Error("responders never joined the topic")
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	replies, err := requester.PubSubRequest(ctx, "presence", []byte("who:"), RequestQuorum(2))
+	if err != nil {
+		t.Errorf("Request returned error `%s`", err.Error())
+		return
+	}
+
+	if len(replies) != 2 || replies[0].From == replies[1].From {
+		t.Errorf("expected one reply from each responder, got %d", len(replies))
+		return
+	}
+
+	for _, r := range replies {
+		if r.Err != nil || string(r.Payload) != "who:"+string(r.From) {
+			t.Errorf("unexpected reply `%s` (%v) from %s", r.Payload, r.Err, r.From)
+		}
+	}
+
+	replies, err = requester.PubSubRequest(ctx, "presence", []byte("who:"), RequestQuorum(3), RequestTimeout(time.Second))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the quorum to time out, got `%v`", err)
+	}
+
+	if len(replies) != 2 {
+		t.Errorf("expected the gathered replies, got %d", len(replies))
+	}
+}
+
+func TestPubSubRequestDuplicates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	requester, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer requester.Close()
+
+	responder, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer responder.Close()
+
+	if err = responder.Peer().Connect(ctx, peercore.AddrInfo{ID: requester.ID(), Addrs: requester.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	// both subscriptions of the responder answer every request
+	deadlines := make(chan time.Duration, 4)
+	for i := 0; i < 2; i++ {
+		_, err = responder.PubSubRespond("echo", func(ctx context.Context, _ peercore.ID, payload []byte) ([]byte, error) {
+			deadline, _ := ctx.Deadline()
+			deadlines <- time.Until(deadline)
+			return payload, nil
+		})
+		if err != nil {
+			t.Errorf("Respond returned error `%s`", err.Error())
+			return
+		}
+	}
+
+	topic, err := requester.PubSubTopic("echo")
+	if err != nil {
+		t.Errorf("Topic returned error `%s`", err.Error())
+		return
+	}
+
+	for len(topic.ListPeers()) < 1 {
+		select {
+		case <-ctx.Done():
+			t.Error("responder never joined the topic")
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	replies, err := requester.PubSubRequest(ctx, "echo", []byte("hi"), RequestTimeout(time.Second))
+	if err != nil {
+		t.Errorf("Request returned error `%s`", err.Error())
+		return
+	}
+
+	if len(replies) != 1 || replies[0].From != responder.ID() {
+		t.Errorf("expected a single reply per peer, got %d", len(replies))
+	}
+
+	replies, err = requester.PubSubRequest(ctx, "echo", []byte("hi"), RequestTimeout(time.Second), RequestDuplicates(true))
+	if err != nil {
+		t.Errorf("Request returned error `%s`", err.Error())
+		return
+	}
+
+	if len(replies) != 2 {
+		t.Errorf("expected the duplicate replies, got %d", len(replies))
+	}
+
+	// responders cap the timeout of requests
+	for len(deadlines) > 0 {
+		<-deadlines
+	}
+
+	if _, err = requester.PubSubRequest(ctx, "echo", []byte("hi"), RequestTimeout(time.Hour), RequestQuorum(1)); err != nil {
+		t.Errorf("Request returned error `%s`", err.Error())
+		return
+	}
+
+	if left := <-deadlines; left <= 0 || left > MaxRespondTimeout {
+		t.Errorf("expected the handler to get at most %s, got %s", MaxRespondTimeout, left)
+	}
+}
+
+func TestRespondTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		timeout, expected time.Duration
+	}{
+		{time.Second, time.Second},
+		{0, MaxRespondTimeout},
+		{-time.Second, MaxRespondTimeout},
+		{time.Hour, MaxRespondTimeout},
+	} {
+		if got := (requestEnvelope{Timeout: int64(tc.timeout)}).respondTimeout(); got != tc.expected {
+			t.Errorf("expected %s for a %s request, got %s", tc.expected, tc.timeout, got)
+		}
+	}
+}
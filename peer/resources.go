@@ -0,0 +1,185 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+)
+
+// ResourceLimits configures the resource manager of a node. Limits start from
+// rcmgr.DefaultLimits, scaled to Memory and FD.
+type ResourceLimits struct {
+	// Memory is the memory the node may use. Zero, with FD, scales the limits
+	// to 1/8 of the system memory and half of the file descriptors.
+	Memory int64
+	// FD is the number of file descriptors the node may use.
+	FD int
+	// Peer bounds what every single peer can use. Zero values keep the scaled
+	// defaults.
+	Peer PeerLimits
+}
+
+// PeerLimits bounds the resources a single peer can use.
+type PeerLimits struct {
+	Conns           int
+	StreamsInbound  int
+	StreamsOutbound int
+	Memory          int64
+}
+
+// ProtocolLimits bounds the inbound streams of a protocol. Zero values keep
+// the scaled defaults.
+type ProtocolLimits struct {
+	// StreamsInbound bounds the inbound streams of the protocol, all peers
+	// together.
+	StreamsInbound int
+	// PeerStreamsInbound bounds the inbound streams of the protocol opened by
+	// a single peer.
+	PeerStreamsInbound int
+	// Memory bounds the memory reserved by the streams of the protocol.
+	Memory int64
+}
+
+// ErrNoResourceLimiter is returned when the resource manager of the node was
+// not installed by it, like one given with WithLibp2pOptions.
+var ErrNoResourceLimiter = errors.New("resource manager is not managed by the node")
+
+// WithResourceLimits sets the limits of the resource manager of the node.
+// They are ignored if a resource manager is given with WithLibp2pOptions.
+func WithResourceLimits(limits ResourceLimits) Option {
+	return func(o *options) error {
+		if limits.Memory < 0 || limits.FD < 0 {
+			return errors.New("resource limits can not be negative")
+		}
+
+		if (limits.Memory == 0) != (limits.FD == 0) {
+			return errors.New("resource limits need both memory and file descriptors, or neither")
+		}
+
+		p := limits.Peer
+		if p.Conns < 0 || p.StreamsInbound < 0 || p.StreamsOutbound < 0 || p.Memory < 0 {
+			return errors.New("peer limits can not be negative")
+		}
+
+		o.resources = limits
+		return nil
+	}
+}
+
+// limiter serves the limits of the resource manager, with protocol limits
+// that can be changed at runtime.
+type limiter struct {
+	rcmgr.Limiter
+
+	lock          sync.RWMutex
+	protocols     map[protocol.ID]rcmgr.BaseLimit
+	protocolPeers map[protocol.ID]rcmgr.BaseLimit
+}
+
+func newLimiter(limits ResourceLimits) *limiter {
+	scaling := rcmgr.DefaultLimits
+	libp2p.SetDefaultServiceLimits(&scaling)
+
+	var concrete rcmgr.ConcreteLimitConfig
+	if limits.Memory == 0 {
+		concrete = scaling.AutoScale()
+	} else {
+		concrete = scaling.Scale(limits.Memory, limits.FD)
+	}
+
+	concrete = rcmgr.PartialLimitConfig{
+		PeerDefault: rcmgr.ResourceLimits{
+			Conns:           rcmgr.LimitVal(limits.Peer.Conns),
+			StreamsInbound:  rcmgr.LimitVal(limits.Peer.StreamsInbound),
+			StreamsOutbound: rcmgr.LimitVal(limits.Peer.StreamsOutbound),
+			Memory:          rcmgr.LimitVal64(limits.Peer.Memory),
+		},
+	}.Build(concrete)
+
+	return &limiter{
+		Limiter:       rcmgr.NewFixedLimiter(concrete),
+		protocols:     make(map[protocol.ID]rcmgr.BaseLimit),
+		protocolPeers: make(map[protocol.ID]rcmgr.BaseLimit),
+	}
+}
+
+func (l *limiter) GetProtocolLimits(pid protocol.ID) rcmgr.Limit {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if limit, ok := l.protocols[pid]; ok {
+		return &limit
+	}
+
+	return l.Limiter.GetProtocolLimits(pid)
+}
+
+func (l *limiter) GetProtocolPeerLimits(pid protocol.ID) rcmgr.Limit {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if limit, ok := l.protocolPeers[pid]; ok {
+		return &limit
+	}
+
+	return l.Limiter.GetProtocolPeerLimits(pid)
+}
+
+// setProtocol sets the limits of pid, and returns the limit of the whole
+// protocol.
+func (l *limiter) setProtocol(pid protocol.ID, limits ProtocolLimits) rcmgr.BaseLimit {
+	protocolLimit := rcmgr.ResourceLimits{
+		StreamsInbound: rcmgr.LimitVal(limits.StreamsInbound),
+		Memory:         rcmgr.LimitVal64(limits.Memory),
+	}
+	peerLimit := rcmgr.ResourceLimits{
+		StreamsInbound: rcmgr.LimitVal(limits.PeerStreamsInbound),
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.protocols[pid] = protocolLimit.Build(l.Limiter.GetProtocolLimits(pid))
+	l.protocolPeers[pid] = peerLimit.Build(l.Limiter.GetProtocolPeerLimits(pid))
+
+	return l.protocols[pid]
+}
+
+// SetProtocolLimits bounds the inbound streams of protocol pid. Streams over
+// the limits are reset. Limits of a single peer apply to peers opening streams
+// afterwards.
+func (p *node) SetProtocolLimits(pid protocol.ID, limits ProtocolLimits) error {
+	if limits.StreamsInbound < 0 || limits.PeerStreamsInbound < 0 || limits.Memory < 0 {
+		return errors.New("protocol limits can not be negative")
+	}
+
+	if p.limiter == nil {
+		return ErrNoResourceLimiter
+	}
+
+	limit := p.limiter.setProtocol(pid, limits)
+
+	// the scope of the protocol may already exist with the previous limits
+	return p.host.Network().ResourceManager().ViewProtocol(pid, func(scope network.ProtocolScope) error {
+		if s, ok := scope.(rcmgr.ResourceScopeLimiter); ok {
+			s.SetLimit(&limit)
+		}
+		return nil
+	})
+}
+
+// ResourceUsage returns the resources currently used by the node, its
+// protocols and peers.
+func (p *node) ResourceUsage() (rcmgr.ResourceManagerStat, error) {
+	state, ok := p.host.Network().ResourceManager().(rcmgr.ResourceManagerState)
+	if !ok {
+		return rcmgr.ResourceManagerStat{}, fmt.Errorf("resource manager %T does not report usage", p.host.Network().ResourceManager())
+	}
+
+	return state.Stat(), nil
+}
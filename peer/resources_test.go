@@ -0,0 +1,100 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestResourceLimits(t *testing.T) {
+	ctx := context.Background()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()), WithResourceLimits(ResourceLimits{
+		Memory: 256 << 20,
+		FD:     256,
+		Peer:   PeerLimits{StreamsInbound: 64},
+	}))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithBootstrap(StandAlone()))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	const pid = "/test/limits"
+	release := make(chan struct{})
+	defer close(release)
+
+	p1.SetStreamHandler(pid, func(s network.Stream) {
+		defer s.Close()
+		s.Write([]byte{1})
+		<-release
+	})
+
+	if err = p1.SetProtocolLimits(pid, ProtocolLimits{PeerStreamsInbound: 2}); err != nil {
+		t.Errorf("SetProtocolLimits returned error `%s`", err.Error())
+		return
+	}
+
+	if err = p2.Peer().Connect(ctx, peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	open := func() error {
+		sctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		s, err := p2.Peer().NewStream(sctx, p1.ID(), pid)
+		if err != nil {
+			return err
+		}
+
+		s.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = s.Read(make([]byte, 1))
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = open(); err != nil {
+			t.Errorf("opening stream %d failed with `%s`", i, err.Error())
+			return
+		}
+	}
+
+	if err = open(); err == nil {
+		t.Error("expected the third stream to be refused")
+		return
+	}
+
+	usage, err := p1.ResourceUsage()
+	if err != nil {
+		t.Errorf("ResourceUsage returned error `%s`", err.Error())
+		return
+	}
+
+	if n := usage.Protocols[pid].NumStreamsInbound; n != 2 {
+		t.Errorf("expected 2 inbound streams for %s, got %d", pid, n)
+	}
+
+	if n := usage.Peers[p2.ID()].NumStreamsInbound; n < 2 {
+		t.Errorf("expected at least 2 inbound streams from %s, got %d", p2.ID(), n)
+	}
+
+	if err = p1.SetProtocolLimits(pid, ProtocolLimits{Memory: -1}); err == nil {
+		t.Error("expected an error for negative limits")
+	}
+
+	if _, err = NewNode(ctx, WithResourceLimits(ResourceLimits{Memory: 1 << 30})); err == nil {
+		t.Error("expected an error for limits without file descriptors")
+	}
+}
@@ -0,0 +1,60 @@
+package peer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	helpers "github.com/taubyte/p2p/helpers"
+)
+
+func TestSecurity(t *testing.T) {
+	ctx := context.Background()
+
+	newNode := func(protocols ...helpers.Security) Node {
+		p, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"), WithSecurity(protocols...), WithBootstrap(StandAlone()))
+		if err != nil {
+			t.Fatalf("NewNode returned error `%s`", err.Error())
+		}
+		return p
+	}
+
+	tls := newNode(helpers.SecurityTLS)
+	defer tls.Close()
+
+	noise := newNode(helpers.SecurityNoise)
+	defer noise.Close()
+
+	both := newNode(helpers.SecurityNoise, helpers.SecurityTLS)
+	defer both.Close()
+
+	if err := tls.Peer().Connect(ctx, peer.AddrInfo{ID: noise.ID(), Addrs: noise.Peer().Addrs()}); err == nil {
+		t.Error("tls only node connected to a noise only node")
+	}
+
+	for _, tc := range []struct {
+		from     Node
+		security string
+	}{
+		{tls, "/tls/1.0.0"},
+		{noise, "/noise"},
+	} {
+		if err := tc.from.Peer().Connect(ctx, peer.AddrInfo{ID: both.ID(), Addrs: both.Peer().Addrs()}); err != nil {
+			t.Errorf("Connect returned error `%s`", err.Error())
+			return
+		}
+
+		conns := tc.from.Peer().Network().ConnsToPeer(both.ID())
+		if len(conns) == 0 || string(conns[0].ConnState().Security) != tc.security {
+			t.Errorf("expected %s security, got %v", tc.security, conns)
+		}
+	}
+
+	if _, err := NewNode(ctx, WithSecurity()); err == nil {
+		t.Error("expected an error without security protocols")
+	}
+
+	if _, err := NewNode(ctx, WithSecurity(helpers.SecurityTLS, helpers.SecurityTLS)); err == nil {
+		t.Error("expected an error for a repeated security protocol")
+	}
+}
@@ -0,0 +1,35 @@
+package peer
+
+import (
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/taubyte/p2p/metrics"
+)
+
+// SetStreamHandler sets the handler for streams of protocol pid. Unlike
+// setting it on Peer() directly, in-flight handlers are waited for when the
+// node closes and new streams are reset while it does.
+func (p *node) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	p.streamHandlersLock.Lock()
+	defer p.streamHandlersLock.Unlock()
+
+	if p.streamHandlersDraining {
+		return
+	}
+
+	p.streamHandlers[pid] = struct{}{}
+	p.host.SetStreamHandler(pid, func(s network.Stream) {
+		p.streamHandlersLock.Lock()
+		if p.streamHandlersDraining {
+			p.streamHandlersLock.Unlock()
+			s.Reset()
+			return
+		}
+		p.streamHandlersWG.Add(1)
+		p.streamHandlersLock.Unlock()
+
+		defer p.streamHandlersWG.Done()
+		p.Metrics().Stream(string(pid), metrics.Inbound)
+		handler(s)
+	})
+}
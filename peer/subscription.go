@@ -0,0 +1,113 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// SubscriptionStats are the counters of a Subscription.
+type SubscriptionStats struct {
+	// Delivered is the number of messages passed to the handler.
+	Delivered uint64
+	// Duplicates is the number of messages dropped as already delivered.
+	Duplicates uint64
+	// Errors is the number of errors passed to the error handler.
+	Errors uint64
+	// LastDelivered is when the last message was passed to the handler.
+	LastDelivered time.Time
+}
+
+// Subscription is a handle on a pubsub subscription of the node.
+type Subscription struct {
+	node    *node
+	topic   *pubsub.Topic
+	subs    *pubsub.Subscription
+	counted bool
+	done    chan struct{}
+
+	delivered     atomic.Uint64
+	duplicates    atomic.Uint64
+	errors        atomic.Uint64
+	lastDelivered atomic.Int64
+}
+
+// Topic returns the name of the subscribed topic.
+func (s *Subscription) Topic() string {
+	return s.subs.Topic()
+}
+
+// Cancel stops the subscription. The topic is left once its last local
+// subscription is cancelled. Cancelling is not reported to the error handler.
+func (s *Subscription) Cancel() {
+	s.subs.Cancel()
+}
+
+// Done is closed once the subscription has stopped and released its topic.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stats returns the counters of the subscription.
+func (s *Subscription) Stats() SubscriptionStats {
+	stats := SubscriptionStats{
+		Delivered:  s.delivered.Load(),
+		Duplicates: s.duplicates.Load(),
+		Errors:     s.errors.Load(),
+	}
+
+	if last := s.lastDelivered.Load(); last > 0 {
+		stats.LastDelivered = time.Unix(0, last)
+	}
+
+	return stats
+}
+
+// run delivers messages to handler until ctx is done or the subscription is
+// cancelled, which are clean stops. Other errors are passed to err_handler.
+// When dedup is set, recently seen messages are dropped.
+func (s *Subscription) run(ctx context.Context, dedup bool, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) {
+	lookup := make(map[string]struct{})
+	max := 1024
+	order := make([]string, 0, max)
+
+	defer s.node.releaseSubscription(s)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			msg, err := s.subs.Next(ctx)
+			if err != nil {
+				if ctx.Err() != nil || errors.Is(err, pubsub.ErrSubscriptionCancelled) {
+					return
+				}
+
+				s.errors.Add(1)
+				err_handler(err)
+				return
+			}
+
+			if dedup {
+				if _, ok := lookup[msg.ID]; ok {
+					s.duplicates.Add(1)
+					continue
+				}
+
+				lookup[msg.ID] = struct{}{}
+				if len(order)+1 >= cap(order) {
+					delete(lookup, order[0])
+					order = order[1:]
+				}
+				order = append(order, msg.ID)
+			}
+
+			s.delivered.Add(1)
+			s.lastDelivered.Store(time.Now().UnixNano())
+			handler(msg)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+func TestSubscriptionCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer n.Close()
+
+	p := n.(*node)
+	hasTopic := func() bool {
+		p.topicsMutex.Lock()
+		defer p.topicsMutex.Unlock()
+		_, ok := p.topics["subs"]
+		return ok
+	}
+
+	received := make(chan struct{}, 1)
+	s1, err := n.PubSubSubscribe("subs", func(*pubsub.Message) { received <- struct{}{} }, func(error) {})
+	if err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	errs := make(chan error, 2)
+	s2, err := n.PubSubSubscribeContext(ctx, "subs", func(*pubsub.Message) {}, func(err error) { errs <- err })
+	if err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	if err = n.PubSubPublish(ctx, "subs", []byte("hello")); err != nil {
+		t.Errorf("Publish returned error `%s`", err.Error())
+		return
+	}
+
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Error("message was not delivered")
+		return
+	}
+
+	if stats := s1.Stats(); stats.Delivered != 1 || stats.LastDelivered.IsZero() {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	s1.Cancel()
+	<-s1.Done()
+	if !hasTopic() {
+		t.Error("topic was left while still subscribed")
+	}
+
+	s2.Cancel()
+	<-s2.Done()
+	if hasTopic() {
+		t.Error("topic was not left after the last subscription")
+	}
+
+	if s2.Stats().Errors != 0 || len(errs) != 0 {
+		t.Errorf("cancellation was reported as an error, got %+v", s2.Stats())
+	}
+
+	if err = n.PubSubPublish(ctx, "subs", []byte("hello")); err != nil {
+		t.Errorf("Publish after leaving returned error `%s`", err.Error())
+	}
+
+	// cancelling the context of a subscription is a clean stop too
+	subsCtx, subsCancel := context.WithCancel(ctx)
+	defer subsCancel()
+
+	s3, err := n.PubSubSubscribeContext(subsCtx, "subs", func(*pubsub.Message) {}, func(err error) { errs <- err })
+	if err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	subsCancel()
+	<-s3.Done()
+	if s3.Stats().Errors != 0 || len(errs) != 0 {
+		t.Errorf("context cancellation was reported as an error, got %+v", s3.Stats())
+	}
+}
+
+func TestSubscriptionCancelKeepsSharedTopic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer n.Close()
+
+	topic, err := n.PubSubTopic("shared")
+	if err != nil {
+		t.Errorf("PubSubTopic returned error `%s`", err.Error())
+		return
+	}
+
+	s, err := n.PubSubSubscribe("shared", func(*pubsub.Message) {}, func(error) {})
+	if err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	s.Cancel()
+	<-s.Done()
+
+	if err = topic.Publish(ctx, []byte("hello")); err != nil {
+		t.Errorf("Publish on the topic handle returned error `%s`", err.Error())
+	}
+}
@@ -0,0 +1,173 @@
+package peer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Codec encodes and decodes values of type T carried on a Topic.
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+type cborCodec[T any] struct{}
+
+func (cborCodec[T]) Encode(value T) ([]byte, error) {
+	return cbor.Marshal(value)
+}
+
+func (cborCodec[T]) Decode(data []byte) (value T, err error) {
+	err = cbor.Unmarshal(data, &value)
+	return
+}
+
+// CBORCodec returns a codec encoding values as CBOR.
+func CBORCodec[T any]() Codec[T] {
+	return cborCodec[T]{}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec[T]) Decode(data []byte) (value T, err error) {
+	err = json.Unmarshal(data, &value)
+	return
+}
+
+// JSONCodec returns a codec encoding values as JSON.
+func JSONCodec[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+// Validator checks a decoded value received from a peer. Messages for which
+// a validator returns an error are rejected and not propagated.
+type Validator[T any] func(ctx context.Context, from peer.ID, value T) error
+
+// Message is a decoded value received on a Topic.
+type Message[T any] struct {
+	Value        T
+	ID           string
+	From         peer.ID
+	ReceivedFrom peer.ID
+	Seqno        uint64
+}
+
+// TopicHandler is called with every message delivered on a Topic.
+type TopicHandler[T any] func(msg *Message[T])
+
+// Topic is a pubsub topic carrying values of type T.
+type Topic[T any] struct {
+	node       Node
+	name       string
+	codec      Codec[T]
+	validators []Validator[T]
+}
+
+// NewTopic joins the topic name and registers a validator rejecting messages
+// that can not be decoded by codec or fail any of validators. Only one Topic
+// can be created per name and node until it is closed.
+func NewTopic[T any](node Node, name string, codec Codec[T], validators ...Validator[T]) (*Topic[T], error) {
+	if codec == nil {
+		return nil, fmt.Errorf("topic `%s` needs a codec", name)
+	}
+
+	if _, err := node.PubSubTopic(name); err != nil {
+		return nil, err
+	}
+
+	t := &Topic[T]{
+		node:       node,
+		name:       name,
+		codec:      codec,
+		validators: validators,
+	}
+
+	if err := node.Messaging().RegisterTopicValidator(name, t.validate); err != nil {
+		return nil, fmt.Errorf("registering validator for topic `%s` failed with: %w", name, err)
+	}
+
+	return t, nil
+}
+
+func (t *Topic[T]) validate(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	value, err := t.codec.Decode(msg.Data)
+	if err != nil {
+		logger.Debugf("rejecting undecodable message on `%s` from %s: %s", t.name, from, err)
+		return pubsub.ValidationReject
+	}
+
+	for _, validator := range t.validators {
+		if err = validator(ctx, msg.GetFrom(), value); err != nil {
+			logger.Debugf("rejecting invalid message on `%s` from %s: %s", t.name, from, err)
+			return pubsub.ValidationReject
+		}
+	}
+
+	msg.ValidatorData = value
+	return pubsub.ValidationAccept
+}
+
+// Name returns the name of the topic.
+func (t *Topic[T]) Name() string {
+	return t.name
+}
+
+// Publish encodes and publishes value.
+func (t *Topic[T]) Publish(ctx context.Context, value T) error {
+	data, err := t.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+
+	return t.node.PubSubPublish(ctx, t.name, data)
+}
+
+// Subscribe calls handler with every valid message received on the topic.
+func (t *Topic[T]) Subscribe(handler TopicHandler[T], err_handler PubSubConsumerErrorHandler) (*Subscription, error) {
+	return t.node.PubSubSubscribe(
+		t.name,
+		func(msg *pubsub.Message) {
+			value, ok := msg.ValidatorData.(T)
+			if !ok {
+				// validator was bypassed, should not happen
+				var err error
+				if value, err = t.codec.Decode(msg.Data); err != nil {
+					err_handler(err)
+					return
+				}
+			}
+
+			handler(&Message[T]{
+				Value:        value,
+				ID:           msg.ID,
+				From:         msg.GetFrom(),
+				ReceivedFrom: msg.ReceivedFrom,
+				Seqno:        seqno(msg),
+			})
+		},
+		err_handler,
+	)
+}
+
+// Close unregisters the topic validator.
+func (t *Topic[T]) Close() error {
+	return t.node.Messaging().UnregisterTopicValidator(t.name)
+}
+
+func seqno(msg *pubsub.Message) uint64 {
+	if len(msg.Seqno) != 8 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(msg.Seqno)
+}
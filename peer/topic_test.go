@@ -0,0 +1,110 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	peercore "github.com/libp2p/go-libp2p/core/peer"
+)
+
+type testRecord struct {
+	Name  string `cbor:"1,keyasint"`
+	Count int    `cbor:"2,keyasint"`
+}
+
+func TestTypedTopic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	p1, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	p2, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Errorf("NewNode returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	err = p2.Peer().Connect(ctx, peercore.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()})
+	if err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	positive := func(_ context.Context, _ peercore.ID, r testRecord) error {
+		if r.Count <= 0 {
+			return errors.New("count must be positive")
+		}
+		return nil
+	}
+
+	t1, err := NewTopic(p1, "records", CBORCodec[testRecord](), positive)
+	if err != nil {
+		t.Errorf("NewTopic returned error `%s`", err.Error())
+		return
+	}
+	defer t1.Close()
+
+	t2, err := NewTopic(p2, "records", CBORCodec[testRecord](), positive)
+	if err != nil {
+		t.Errorf("NewTopic returned error `%s`", err.Error())
+		return
+	}
+	defer t2.Close()
+
+	received := make(chan *Message[testRecord], 8)
+	_, err = t1.Subscribe(func(msg *Message[testRecord]) { received <- msg }, func(error) {})
+	if err != nil {
+		t.Errorf("Subscribe returned error `%s`", err.Error())
+		return
+	}
+
+	topic, _ := p2.PubSubTopic("records")
+	for len(topic.ListPeers()) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Error("peers never joined the topic")
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err = t2.Publish(ctx, testRecord{Name: "bad", Count: 0}); err == nil {
+		t.Error("publishing an invalid record should fail")
+	}
+
+	if err = p2.PubSubPublish(ctx, "records", []byte("not cbor")); err == nil {
+		t.Error("publishing an undecodable message should fail")
+	}
+
+	if err = t2.Publish(ctx, testRecord{Name: "good", Count: 1}); err != nil {
+		t.Errorf("Publish returned error `%s`", err.Error())
+		return
+	}
+
+	select {
+	case msg := <-received:
+		if msg.Value.Name != "good" || msg.Value.Count != 1 {
+			t.Errorf("unexpected record %+v", msg.Value)
+		}
+		if msg.From != p2.ID() {
+			t.Errorf("unexpected sender %s", msg.From)
+		}
+		if msg.Seqno == 0 {
+			t.Error("missing sequence number")
+		}
+	case <-ctx.Done():
+		t.Error("record was not received")
+	}
+
+	if _, err = NewTopic(p1, "records", JSONCodec[testRecord]()); err == nil {
+		t.Error("creating the same typed topic twice should fail")
+	}
+}
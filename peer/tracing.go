@@ -0,0 +1,32 @@
+package peer
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// WithTracerProvider makes the node trace the commands it sends and handles
+// with tp. Trace contexts are propagated to other nodes in commands.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) error {
+		if tp == nil {
+			return errors.New("tracer provider is nil")
+		}
+
+		o.tracerProvider = tp
+		return nil
+	}
+}
+
+// TracerProvider returns the tracer provider of the node, a no-op one if none
+// was set. Spans of the no-op provider still carry remote trace contexts
+// along.
+func (p *node) TracerProvider() trace.TracerProvider {
+	if p.tracerProvider == nil {
+		return noop.NewTracerProvider()
+	}
+
+	return p.tracerProvider
+}
@@ -0,0 +1,86 @@
+package peer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	helpers "github.com/taubyte/p2p/helpers"
+)
+
+func TestTransports(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	for _, tc := range []struct {
+		transport helpers.Transports
+		listen    string
+		protocol  string
+	}{
+		{helpers.TransportTCP, "/ip4/127.0.0.1/tcp/0", "tcp"},
+		{helpers.TransportQUIC, "/ip4/127.0.0.1/udp/0/quic-v1", "quic-v1"},
+		{helpers.TransportWebSocket, "/ip4/127.0.0.1/tcp/0/ws", "websocket"},
+		{helpers.TransportWebTransport, "/ip4/127.0.0.1/udp/0/quic-v1/webtransport", "webtransport"},
+	} {
+		p1, err := NewNode(ctx, WithTransports(tc.transport), WithListen(tc.listen), WithBootstrap(StandAlone()))
+		if err != nil {
+			t.Errorf("NewNode with %s returned error `%s`", tc.transport, err.Error())
+			return
+		}
+		defer p1.Close()
+
+		p2, err := NewNode(ctx, WithTransports(tc.transport), WithListen(tc.listen), WithBootstrap(StandAlone()))
+		if err != nil {
+			t.Errorf("NewNode with %s returned error `%s`", tc.transport, err.Error())
+			return
+		}
+		defer p2.Close()
+
+		if err = p2.Peer().Connect(ctx, peer.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}); err != nil {
+			t.Errorf("Connect over %s returned error `%s`", tc.transport, err.Error())
+			return
+		}
+
+		conns := p2.Peer().Network().ConnsToPeer(p1.ID())
+		if len(conns) == 0 || conns[0].ConnState().Transport != tc.protocol {
+			t.Errorf("expected a %s connection, got %v", tc.protocol, conns)
+		}
+	}
+}
+
+func TestTransportsValidation(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := NewNode(ctx, WithListen("/ip4/127.0.0.1/udp/0/quic-v1")); err == nil {
+		t.Error("expected an error for a quic address without the quic transport")
+	}
+
+	if _, err := NewNode(ctx, WithTransports(helpers.TransportTCP|helpers.TransportQUIC), WithListen("/ip4/127.0.0.1/tcp/0/ws")); err == nil {
+		t.Error("expected an error for a websocket address without the websocket transport")
+	}
+
+	if _, err := NewNode(ctx, WithTransports(helpers.TransportQUIC), WithSwarmKey([]byte("/key/swarm/psk/1.0.0/\n/base16/\n"+strings.Repeat("ab", 32)))); err == nil {
+		t.Error("expected an error for quic with a swarm key")
+	}
+
+	if _, err := NewNode(ctx, WithTransports(0)); err == nil {
+		t.Error("expected an error without transports")
+	}
+
+	// transports are validated before the datastore is opened
+	repo := t.TempDir()
+	if _, err := NewNode(ctx, WithRepoPath(repo), WithListen("/ip4/127.0.0.1/udp/0/quic-v1/webtransport")); err == nil {
+		t.Error("expected an error for a webtransport address without the webtransport transport")
+	}
+
+	if entries, err := os.ReadDir(repo); err != nil || len(entries) != 0 {
+		t.Errorf("datastore was opened for an invalid node: %v (%v)", entries, err)
+	}
+
+	if s := (helpers.TransportTCP | helpers.TransportWebSocket).String(); s != "tcp|websocket" {
+		t.Errorf("unexpected transports name `%s`", s)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	crypto "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/pnet"
+	helpers "github.com/taubyte/p2p/helpers"
+	"github.com/taubyte/p2p/metrics"
 	"github.com/taubyte/utils/fs/dir"
 
 	ipfslite "github.com/hsanjuan/ipfs-lite"
@@ -16,10 +19,15 @@ import (
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/config"
 	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 
 	routing "github.com/libp2p/go-libp2p/core/routing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type BootstrapParams struct {
@@ -30,16 +38,24 @@ type BootstrapParams struct {
 type Node interface {
 	AddFile(r io.Reader) (string, error)
 	AddFileForCid(r io.Reader) (cid.Cid, error)
+	BootstrapReport() helpers.BootstrapReport
 	Close()
+	CloseContext(ctx context.Context) error
 	Context() context.Context
 	DAG() *ipfslite.Peer
 	DeleteFile(id string) error
+	Diagnostics() Diagnostics
 	Discovery() discovery.Discovery
 	Done() <-chan struct{}
+	Events(ctx context.Context) <-chan Event
+	Gater() *Gater
 	GetFile(ctx context.Context, id string) (ReadSeekCloser, error)
 	GetFileFromCid(ctx context.Context, cid cid.Cid) (ReadSeekCloser, error)
+	GetValue(ctx context.Context, key string) (*Record, error)
 	ID() peer.ID
+	KeepAlive(ctx context.Context, name string, opts ...KeepAliveOption) (*KeepAlive, error)
 	Messaging() *pubsub.PubSub
+	Metrics() metrics.Metrics
 	NewChildContextWithCancel() (context.Context, context.CancelFunc)
 	NewFolder(name string) (dir.Directory, error)
 	NewPubSubKeepAlive(ctx context.Context, cancel context.CancelFunc, name string) error
@@ -47,11 +63,23 @@ type Node interface {
 	Peering() PeeringService
 	Ping(pid string, count int) (int, time.Duration, error)
 	PubSubPublish(ctx context.Context, name string, data []byte) error
-	PubSubSubscribe(name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) error
-	PubSubSubscribeContext(ctx context.Context, name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) error
-	PubSubSubscribeToTopic(topic *pubsub.Topic, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) error
+	PubSubRequest(ctx context.Context, topic string, payload []byte, opts ...RequestOption) ([]*Reply, error)
+	PubSubRespond(topic string, handler RequestHandler) (*Subscription, error)
+	PubSubStats(topic string) (TopicStats, error)
+	PubSubTopic(name string) (*pubsub.Topic, error)
+	PubSubSubscribe(name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) (*Subscription, error)
+	PubSubSubscribeContext(ctx context.Context, name string, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) (*Subscription, error)
+	PubSubSubscribeToTopic(topic *pubsub.Topic, handler PubSubConsumerHandler, err_handler PubSubConsumerErrorHandler) (*Subscription, error)
+	PutValue(ctx context.Context, key string, value []byte, opts ...RecordOption) error
+	Reachability() ReachabilityStatus
+	ResourceUsage() (rcmgr.ResourceManagerStat, error)
+	SearchValue(ctx context.Context, key string) (<-chan *Record, error)
+	SetProtocolLimits(pid protocol.ID, limits ProtocolLimits) error
+	SetStreamHandler(pid protocol.ID, handler network.StreamHandler)
 	SimpleAddrsFactory(announce []string, override bool) config.Option
+	State() State
 	Store() datastore.Batching
+	TracerProvider() trace.TracerProvider
 	WaitForSwarm(timeout time.Duration) error
 }
 
@@ -61,6 +89,7 @@ type node struct {
 	ephemeral_repo_path bool
 	repo_path           string
 	store               datastore.Batching
+	external_store      bool
 	key                 crypto.PrivKey
 	id                  peer.ID
 	secret              pnet.PSK
@@ -68,12 +97,43 @@ type node struct {
 	dht                 routing.Routing
 	drouter             discovery.Discovery
 	messaging           *pubsub.PubSub
+	pubsubStats         *pubsubStats
+	pubsubTrace         *pubsub.JSONTracer
 	ipfs                *ipfslite.Peer
+	ipfs_ctx_cancel     context.CancelFunc
+	ipfs_store          *ipfsStore
 	peering             PeeringService
+	limiter             *limiter
+	gater               *Gater
+	metrics             metrics.Metrics
+	tracerProvider      trace.TracerProvider
 
-	topicsMutex sync.Mutex
-	topics      map[string]*pubsub.Topic
-	closed      bool
+	topicsMutex     sync.Mutex
+	topics          map[string]*pubsub.Topic
+	topicRefs       map[string]int
+	sharedTopics    map[string]struct{}
+	subscriptions   map[*Subscription]struct{}
+	subscriptionsWG sync.WaitGroup
+
+	streamHandlersLock     sync.Mutex
+	streamHandlers         map[protocol.ID]struct{}
+	streamHandlersWG       sync.WaitGroup
+	streamHandlersDraining bool
+
+	requestsOnce sync.Once
+	requests     sync.Map
+
+	state      atomic.Int32
+	events     *eventHub
+	hostEvents event.Subscription
+
+	reachability reachability
+
+	bootstrapLock   sync.Mutex
+	bootstrapReport helpers.BootstrapReport
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 func (p *node) ID() peer.ID {
@@ -111,6 +171,9 @@ func (p *node) Context() context.Context {
 type PeeringService interface {
 	Start() error
 	Stop() error
-	AddPeer(peer.AddrInfo)
+	AddPeer(info peer.AddrInfo, opts ...PeeringOption)
+	AddPeerByName(ctx context.Context, name string, opts ...PeeringOption) ([]peer.ID, error)
 	RemovePeer(peer.ID)
+	ListPeers() []PeerStatus
+	Events(ctx context.Context) <-chan Event
 }
@@ -12,9 +12,14 @@ import (
 
 	"golang.org/x/exp/slices"
 
+	"github.com/taubyte/p2p/metrics"
 	"github.com/taubyte/p2p/peer"
 	cr "github.com/taubyte/p2p/streams/command/response"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/libp2p/go-libp2p/core/discovery"
 	"github.com/libp2p/go-libp2p/core/network"
 	peerCore "github.com/libp2p/go-libp2p/core/peer"
@@ -26,14 +31,16 @@ import (
 )
 
 type Client struct {
-	ctx  context.Context
-	ctxC context.CancelFunc
-	node peer.Node
-	path string
+	ctx    context.Context
+	ctxC   context.CancelFunc
+	node   peer.Node
+	path   string
+	tracer trace.Tracer
 }
 
 type Request struct {
 	client     *Client
+	ctx        context.Context
 	to         []peerCore.ID
 	cmd        string
 	body       command.Body
@@ -65,6 +72,19 @@ func Threshold(threshold int) Option {
 	}
 }
 
+// Context sets the context of the request. Its trace context is propagated
+// to the peers, and the request stops once it is done.
+func Context(ctx context.Context) Option {
+	return func(s *Request) error {
+		if ctx == nil {
+			return errors.New("context is nil")
+		}
+
+		s.ctx = ctx
+		return nil
+	}
+}
+
 func To(peers ...peerCore.ID) Option {
 	return func(s *Request) error {
 		s.to = append(s.to, peers...)
@@ -126,6 +146,8 @@ var (
 	logger log.StandardLogger
 )
 
+const tracerName = "github.com/taubyte/p2p/streams/client"
+
 func init() {
 	logger = log.Logger("p2p.streams.client")
 }
@@ -136,8 +158,9 @@ func (c *Client) Context() context.Context {
 
 func New(node peer.Node, path string) (*Client, error) {
 	c := &Client{
-		node: node,
-		path: path,
+		node:   node,
+		path:   path,
+		tracer: node.TracerProvider().Tracer(tracerName),
 	}
 
 	c.ctx, c.ctxC = context.WithCancel(node.Context())
@@ -168,21 +191,47 @@ func (r *Request) Do() (<-chan *Response, error) {
 		return nil, r.err
 	}
 
+	parent := r.ctx
+	if parent == nil {
+		parent = r.client.ctx
+	}
+
+	ctx, span := r.client.tracer.Start(parent, "request "+r.cmd, trace.WithAttributes(
+		attribute.String("p2p.command", r.cmd),
+		attribute.String("p2p.protocol", r.client.path),
+	))
+
 	strms := make([]stream, 0, r.threshold)
 	if len(r.to) > 0 {
 		for _, pid := range r.to {
 			if len(strms) >= r.threshold {
 				break
 			}
-			strm, err := r.client.openStream(pid)
+			strm, err := r.client.openStream(ctx, pid)
 			if err != nil {
+				endSpan(span, err)
 				return nil, err
 			}
 			strms = append(strms, strm)
 		}
 	}
 
-	return r.client.send(r.cmd, r.body, strms, r.threshold, r.cmdTimeout)
+	responses, err := r.client.send(ctx, r.cmd, r.body, strms, r.threshold, r.cmdTimeout)
+	if err != nil {
+		endSpan(span, err)
+	}
+
+	return responses, err
+}
+
+// endSpan ends span, failed with err if not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
 }
 
 func (r *Response) CloseRead() {
@@ -197,12 +246,17 @@ func (r *Response) Close() {
 	r.ReadWriter.(streamAsReadWriter).ReadWriter.(network.Stream).Reset()
 }
 
-func (c *Client) openStream(pid peerCore.ID) (stream, error) {
-	strm, err := c.node.Peer().NewStream(c.ctx, pid, protocol.ID(c.path))
+func (c *Client) openStream(ctx context.Context, pid peerCore.ID) (stream, error) {
+	ctx, span := c.tracer.Start(ctx, "connect", trace.WithAttributes(attribute.String("p2p.peer", pid.String())))
+	strm, err := c.node.Peer().NewStream(ctx, pid, protocol.ID(c.path))
 	if err != nil {
-		return stream{}, fmt.Errorf("peer new stream failed with: %w", err)
+		err = fmt.Errorf("peer new stream failed with: %w", err)
+		endSpan(span, err)
+		return stream{}, err
 	}
+	span.End()
 
+	c.node.Metrics().Stream(c.path, metrics.Outbound)
 	return stream{Stream: strm, ID: pid}, nil
 }
 
@@ -215,7 +269,9 @@ func (c *Client) discover(ctx context.Context) <-chan peerCore.AddrInfo {
 
 	peers := make(chan peerCore.AddrInfo, cap)
 
+	ctx, span := c.tracer.Start(ctx, "discover")
 	go func() {
+		defer span.End()
 		defer close(peers)
 		proto := protocol.ID(c.path)
 
@@ -264,31 +320,70 @@ func (c *Client) discover(ctx context.Context) <-chan peerCore.AddrInfo {
 	return peers
 }
 
-func (c *Client) connect(peer peerCore.AddrInfo) (network.Stream, bool, error) {
+// connect opens a stream to peer if connected. Otherwise it dials peer, at
+// most once per dials, and asks to be retried while the dial is in flight.
+func (c *Client) connect(ctx context.Context, peer peerCore.AddrInfo, dials map[peerCore.ID]chan struct{}) (network.Stream, bool, error) {
 	switch c.node.Peer().Network().Connectedness(peer.ID) {
 	case network.Connected:
 	case network.CanConnect, network.NotConnected:
-		go c.node.Peer().Connect(c.ctx, peer)
+		if done, ok := dials[peer.ID]; ok {
+			select {
+			case <-done:
+				// the dial failed
+				return nil, false, nil
+			default:
+				return nil, true, nil
+			}
+		}
+
+		done := make(chan struct{})
+		dials[peer.ID] = done
+
+		_, span := c.tracer.Start(ctx, "connect", trace.WithAttributes(
+			attribute.String("p2p.peer", peer.ID.String()),
+			attribute.Bool("p2p.dial", true),
+		))
+		go func() {
+			defer close(done)
+			endSpan(span, c.node.Peer().Connect(c.ctx, peer))
+		}()
 		return nil, true, nil
 	default:
 		return nil, false, nil
 	}
 
+	_, span := c.tracer.Start(ctx, "connect", trace.WithAttributes(attribute.String("p2p.peer", peer.ID.String())))
 	strm, err := c.node.Peer().NewStream(
 		network.WithNoDial(c.ctx, "application ensured connection to peer exists"),
 		peer.ID,
 		protocol.ID(c.path),
 	)
+	endSpan(span, err)
 	if err != nil {
 		logger.Errorf("starting stream to `%s`;`%s` failed with: %w", peer.ID.String(), c.path, err)
 		return nil, false, err
 	}
+	c.node.Metrics().Stream(c.path, metrics.Outbound)
 
 	return strm, false, nil
 }
 
-func (c *Client) sendTo(strm stream, deadline time.Time, cmdName string, body command.Body) *Response {
+func (c *Client) sendTo(ctx context.Context, strm stream, deadline time.Time, cmdName string, body command.Body) *Response {
+	start := time.Now()
+	ctx, span := c.tracer.Start(ctx, "command "+cmdName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("p2p.command", cmdName),
+		attribute.String("p2p.peer", strm.ID.String()),
+	))
+
+	resp := c.sendCommand(ctx, strm, deadline, cmdName, body)
+	endSpan(span, resp.err)
+	c.node.Metrics().Command(cmdName, metrics.Outbound, time.Since(start), resp.err)
+	return resp
+}
+
+func (c *Client) sendCommand(ctx context.Context, strm stream, deadline time.Time, cmdName string, body command.Body) *Response {
 	cmd := command.New(cmdName, body)
+	cmd.InjectTrace(ctx)
 	rw := streamAsReadWriter{strm.Stream}
 
 	if err := strm.SetWriteDeadline(deadline); err != nil {
@@ -300,7 +395,10 @@ func (c *Client) sendTo(strm stream, deadline time.Time, cmdName string, body co
 	}
 	defer strm.SetWriteDeadline(time.Time{})
 
-	if err := cmd.Encode(strm); err != nil {
+	_, span := c.tracer.Start(ctx, "send")
+	err := cmd.Encode(strm)
+	endSpan(span, err)
+	if err != nil {
 		return &Response{
 			ReadWriter: rw,
 			pid:        strm.ID,
@@ -317,7 +415,9 @@ func (c *Client) sendTo(strm stream, deadline time.Time, cmdName string, body co
 	}
 	defer strm.SetReadDeadline(time.Time{})
 
+	_, span = c.tracer.Start(ctx, "receive")
 	resp, err := cr.Decode(strm)
+	endSpan(span, err)
 	if err != nil {
 		return &Response{
 			ReadWriter: rw,
@@ -341,7 +441,10 @@ func (c *Client) sendTo(strm stream, deadline time.Time, cmdName string, body co
 	}
 }
 
-func (c *Client) send(cmdName string, body command.Body, streams []stream, minStreams int, timeout time.Duration) (<-chan *Response, error) {
+// send sends the command on streams, and on streams to discovered peers up
+// to minStreams. reqCtx is the context of the request, carrying its span
+// which ends once all responses are in.
+func (c *Client) send(reqCtx context.Context, cmdName string, body command.Body, streams []stream, minStreams int, timeout time.Duration) (<-chan *Response, error) {
 	if timeout == 0 {
 		timeout = SendToPeerTimeout
 	}
@@ -350,7 +453,9 @@ func (c *Client) send(cmdName string, body command.Body, streams []stream, minSt
 		return nil, fmt.Errorf("threashold %d exceeds MaxStreamsPerSend", minStreams)
 	}
 
-	ctx, ctxC := context.WithTimeout(c.ctx, timeout)
+	span := trace.SpanFromContext(reqCtx)
+	ctx, ctxC := context.WithTimeout(trace.ContextWithSpan(c.ctx, span), timeout)
+	stop := context.AfterFunc(reqCtx, ctxC)
 	cmdDD, _ := ctx.Deadline()
 
 	discPeers := c.discover(ctx)
@@ -373,6 +478,8 @@ func (c *Client) send(cmdName string, body command.Body, streams []stream, minSt
 		peers := make(chan peerCore.AddrInfo, MaxStreamsPerSend)
 		defer close(peers)
 
+		dials := make(map[peerCore.ID]chan struct{})
+
 		for {
 			if strmsCount >= minStreams {
 				return
@@ -383,7 +490,7 @@ func (c *Client) send(cmdName string, body command.Body, streams []stream, minSt
 					peers <- peer
 				}
 			case peer := <-peers:
-				strm, repush, _ := c.connect(peer)
+				strm, repush, _ := c.connect(ctx, peer, dials)
 				if strm != nil && strmsCount < minStreams {
 					strmsCount++
 					strms <- stream{Stream: strm, ID: peer.ID}
@@ -400,16 +507,21 @@ func (c *Client) send(cmdName string, body command.Body, streams []stream, minSt
 	responses := make(chan *Response, MaxStreamsPerSend)
 	go func() {
 		var wg sync.WaitGroup
+		var count int
 		defer func() {
 			wg.Wait()
 			close(responses)
+			stop()
 			ctxC()
+			span.SetAttributes(attribute.Int("p2p.streams", count))
+			span.End()
 		}()
 		for strm := range strms {
+			count++
 			wg.Add(1)
 			go func(_strm stream) {
 				defer wg.Done()
-				responses <- c.sendTo(_strm, cmdDD, cmdName, body)
+				responses <- c.sendTo(ctx, _strm, cmdDD, cmdName, body)
 			}(strm)
 		}
 	}()
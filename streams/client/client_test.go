@@ -2,14 +2,18 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	helpers "github.com/taubyte/p2p/helpers"
 	keypair "github.com/taubyte/p2p/keypair"
+	"github.com/taubyte/p2p/metrics"
 
 	peer "github.com/taubyte/p2p/peer"
 	"github.com/taubyte/p2p/streams/command"
@@ -20,6 +24,8 @@ import (
 
 	logging "github.com/ipfs/go-log/v2"
 	peercore "github.com/libp2p/go-libp2p/core/peer"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestClientSend(t *testing.T) {
@@ -526,12 +532,14 @@ func TestClientMultiSend(t *testing.T) {
 		return
 	}
 
+	// not n: with SO_REUSEPORT both providers listen on the same port and
+	// dials reach either of them, failing the peer id check
 	p2, err := peer.New( // provider
 		ctx,
 		nil,
 		keypair.NewRaw(),
 		nil,
-		[]string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", n)},
+		[]string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", n+2)},
 		nil,
 		true,
 		false,
@@ -626,3 +634,369 @@ func TestClientMultiSend(t *testing.T) {
 
 	cd.Close()
 }
+
+func TestClientSecurity(t *testing.T) {
+	ctx, ctxC := context.WithCancel(context.Background())
+	defer ctxC()
+
+	p1, err := peer.NewNode(ctx, peer.WithListen("/ip4/127.0.0.1/tcp/0"), peer.WithSecurity(helpers.SecurityNoise))
+	if err != nil {
+		t.Errorf("Peer creation returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	svr, err := peerService.New(p1, "hello", "/hello/1.0")
+	if err != nil {
+		t.Errorf("Service creation returned error `%s`", err.Error())
+		return
+	}
+	defer svr.Stop()
+
+	err = svr.Define("security", func(_ context.Context, conn streams.Connection, _ command.Body) (cr.Response, error) {
+		return cr.Response{"security": string(streams.Security(conn))}, nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	p2, err := peer.NewNode(ctx, peer.WithListen("/ip4/127.0.0.1/tcp/0"), peer.WithSecurity(helpers.SecurityTLS, helpers.SecurityNoise))
+	if err != nil {
+		t.Errorf("Peer creation returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	err = p2.Peer().Connect(ctx, peercore.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()})
+	if err != nil {
+		t.Errorf("Connect to peer %v returned `%s`", p1.Peer().Addrs(), err.Error())
+		return
+	}
+
+	c, err := New(p2, "/hello/1.0")
+	if err != nil {
+		t.Errorf("Client creation returned error `%s`", err.Error())
+		return
+	}
+	defer c.Close()
+
+	res, err := c.SendTo(p1.ID(), "security", command.Body{})
+	if err != nil {
+		t.Errorf("Sending command returned error `%s`", err.Error())
+		return
+	}
+
+	if v, err := res.Get("security"); err != nil || v.(string) != "/noise" {
+		t.Errorf("unexpected negotiated security %v", v)
+	}
+}
+
+type commandRecorder struct {
+	metrics.Noop
+
+	lock     sync.Mutex
+	commands map[string]int
+	errors   map[string]int
+	streams  map[string]int
+}
+
+func newCommandRecorder() *commandRecorder {
+	return &commandRecorder{commands: make(map[string]int), errors: make(map[string]int), streams: make(map[string]int)}
+}
+
+func (r *commandRecorder) Command(name string, dir metrics.Direction, _ time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := string(dir) + ":" + name
+	r.commands[key]++
+	if err != nil {
+		r.errors[key]++
+	}
+}
+
+func (r *commandRecorder) Stream(protocol string, dir metrics.Direction) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.streams[string(dir)+":"+protocol]++
+}
+
+func (r *commandRecorder) counts(key string) (int, int, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.commands[key], r.errors[key], r.streams[key]
+}
+
+func TestClientMetrics(t *testing.T) {
+	ctx, ctxC := context.WithCancel(context.Background())
+	defer ctxC()
+
+	server, client := newCommandRecorder(), newCommandRecorder()
+
+	p1, err := peer.NewNode(ctx, peer.WithListen("/ip4/127.0.0.1/tcp/0"), peer.WithBootstrap(peer.StandAlone()), peer.WithMetrics(server))
+	if err != nil {
+		t.Errorf("Peer creation returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	svr, err := peerService.New(p1, "hello", "/hello/1.0")
+	if err != nil {
+		t.Errorf("Service creation returned error `%s`", err.Error())
+		return
+	}
+	defer svr.Stop()
+
+	err = svr.Define("hi", func(context.Context, streams.Connection, command.Body) (cr.Response, error) {
+		return cr.Response{"message": "HI"}, nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	p2, err := peer.NewNode(ctx, peer.WithListen("/ip4/127.0.0.1/tcp/0"), peer.WithBootstrap(peer.StandAlone()), peer.WithMetrics(client))
+	if err != nil {
+		t.Errorf("Peer creation returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	if err = p2.Peer().Connect(ctx, peercore.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	c, err := New(p2, "/hello/1.0")
+	if err != nil {
+		t.Errorf("Client creation returned error `%s`", err.Error())
+		return
+	}
+	defer c.Close()
+
+	if _, err = c.SendTo(p1.ID(), "hi", nil); err != nil {
+		t.Errorf("Sending hi returned error `%s`", err.Error())
+		return
+	}
+
+	if _, err = c.SendTo(p1.ID(), "made-up", nil); err == nil {
+		t.Error("expected an error sending an unknown command")
+	}
+
+	if commands, errs, _ := client.counts("outbound:hi"); commands != 1 || errs != 0 {
+		t.Errorf("expected one successful outbound hi, got %d with %d errors", commands, errs)
+	}
+
+	if commands, errs, _ := client.counts("outbound:made-up"); commands != 1 || errs != 1 {
+		t.Errorf("expected one failed outbound made-up, got %d with %d errors", commands, errs)
+	}
+
+	if _, _, strms := client.counts("outbound:/hello/1.0"); strms != 2 {
+		t.Errorf("expected 2 outbound streams, got %d", strms)
+	}
+
+	// the server measures once it answered, after the client may have read
+	// the response
+	time.Sleep(100 * time.Millisecond)
+
+	if commands, errs, _ := server.counts("inbound:hi"); commands != 1 || errs != 0 {
+		t.Errorf("expected one successful inbound hi, got %d with %d errors", commands, errs)
+	}
+
+	if commands, errs, _ := server.counts("inbound:unknown"); commands != 1 || errs != 1 {
+		t.Errorf("expected one failed inbound unknown command, got %d with %d errors", commands, errs)
+	}
+
+	if _, _, strms := server.counts("inbound:/hello/1.0"); strms != 2 {
+		t.Errorf("expected 2 inbound streams, got %d", strms)
+	}
+}
+
+func newTracedNode(t *testing.T, ctx context.Context) (peer.Node, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	p, err := peer.NewNode(ctx, peer.WithListen("/ip4/127.0.0.1/tcp/0"), peer.WithBootstrap(peer.StandAlone()), peer.WithTracerProvider(provider))
+	if err != nil {
+		t.Fatalf("Peer creation returned error `%s`", err.Error())
+	}
+	t.Cleanup(p.Close)
+
+	return p, exporter
+}
+
+// waitSpan returns the span of exporter called name, once ended.
+func waitSpan(exporter *tracetest.InMemoryExporter, name string) (tracetest.SpanStub, bool) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(20 * time.Millisecond) {
+		for _, span := range exporter.GetSpans() {
+			if span.Name == name {
+				return span, true
+			}
+		}
+	}
+
+	return tracetest.SpanStub{}, false
+}
+
+func TestClientTracing(t *testing.T) {
+	ctx, ctxC := context.WithCancel(context.Background())
+	defer ctxC()
+
+	front, frontSpans := newTracedNode(t, ctx)
+	back, backSpans := newTracedNode(t, ctx)
+	caller, callerSpans := newTracedNode(t, ctx)
+
+	backSvr, err := peerService.New(back, "back", "/back/1.0")
+	if err != nil {
+		t.Errorf("Service creation returned error `%s`", err.Error())
+		return
+	}
+	defer backSvr.Stop()
+
+	backSvr.Define("leaf", func(context.Context, streams.Connection, command.Body) (cr.Response, error) {
+		return cr.Response{"message": "LEAF"}, nil
+	})
+
+	frontClient, err := New(front, "/back/1.0")
+	if err != nil {
+		t.Errorf("Client creation returned error `%s`", err.Error())
+		return
+	}
+	defer frontClient.Close()
+
+	frontSvr, err := peerService.New(front, "front", "/front/1.0")
+	if err != nil {
+		t.Errorf("Service creation returned error `%s`", err.Error())
+		return
+	}
+	defer frontSvr.Stop()
+
+	// the front handler calls the back node within the trace of the command
+	frontSvr.Define("hi", func(ctx context.Context, _ streams.Connection, _ command.Body) (cr.Response, error) {
+		resCh, err := frontClient.New("leaf", To(back.ID()), Context(ctx)).Do()
+		if err != nil {
+			return nil, err
+		}
+
+		res := <-resCh
+		if res == nil {
+			return nil, errors.New("no response")
+		}
+		defer res.Close()
+
+		return res.Response, res.Error()
+	})
+
+	for _, p := range []peer.Node{caller, back} {
+		if err = p.Peer().Connect(ctx, peercore.AddrInfo{ID: front.ID(), Addrs: front.Peer().Addrs()}); err != nil {
+			t.Errorf("Connect returned error `%s`", err.Error())
+			return
+		}
+	}
+
+	c, err := New(caller, "/front/1.0")
+	if err != nil {
+		t.Errorf("Client creation returned error `%s`", err.Error())
+		return
+	}
+	defer c.Close()
+
+	res, err := c.SendTo(front.ID(), "hi", nil)
+	if err != nil {
+		t.Errorf("Sending hi returned error `%s`", err.Error())
+		return
+	}
+
+	if msg, _ := res.Get("message"); msg != "LEAF" {
+		t.Errorf("unexpected response %v", res)
+	}
+
+	var spans = make(map[string]tracetest.SpanStub)
+	for _, s := range []struct {
+		exporter *tracetest.InMemoryExporter
+		name     string
+	}{
+		{callerSpans, "request hi"},
+		{callerSpans, "connect"},
+		{callerSpans, "command hi"},
+		{callerSpans, "send"},
+		{callerSpans, "receive"},
+		{frontSpans, "handle hi"},
+		{frontSpans, "request leaf"},
+		{frontSpans, "command leaf"},
+		{backSpans, "handle leaf"},
+	} {
+		span, ok := waitSpan(s.exporter, s.name)
+		if !ok {
+			t.Errorf("missing span `%s`", s.name)
+			return
+		}
+		spans[s.name] = span
+	}
+
+	traceID := spans["request hi"].SpanContext.TraceID()
+	for name, span := range spans {
+		if span.SpanContext.TraceID() != traceID {
+			t.Errorf("span `%s` is not in the trace of the request", name)
+		}
+	}
+
+	for child, parent := range map[string]string{
+		"command hi":   "request hi",
+		"send":         "command hi",
+		"receive":      "command hi",
+		"handle hi":    "command hi",
+		"request leaf": "handle hi",
+		"command leaf": "request leaf",
+		"handle leaf":  "command leaf",
+	} {
+		if spans[child].Parent.SpanID() != spans[parent].SpanContext.SpanID() {
+			t.Errorf("span `%s` is not a child of `%s`", child, parent)
+		}
+	}
+
+	if !spans["handle hi"].Parent.IsRemote() {
+		t.Error("expected the handler span to have a remote parent")
+	}
+}
+
+func TestClientConnectDialsOnce(t *testing.T) {
+	ctx, ctxC := context.WithCancel(context.Background())
+	defer ctxC()
+
+	caller, callerSpans := newTracedNode(t, ctx)
+	target, _ := newTracedNode(t, ctx)
+
+	c, err := New(caller, "/hello/1.0")
+	if err != nil {
+		t.Errorf("Client creation returned error `%s`", err.Error())
+		return
+	}
+	defer c.Close()
+
+	info := peercore.AddrInfo{ID: target.ID(), Addrs: target.Peer().Addrs()}
+	dials := make(map[peercore.ID]chan struct{})
+	for i := 0; i < 3; i++ {
+		if _, repush, _ := c.connect(ctx, info, dials); !repush && i == 0 {
+			t.Error("expected the peer to be retried while dialing")
+			return
+		}
+	}
+
+	<-dials[target.ID()]
+
+	var dialSpans int
+	for _, span := range callerSpans.GetSpans() {
+		if span.Name == "connect" {
+			dialSpans++
+		}
+	}
+
+	if dialSpans != 1 {
+		t.Errorf("expected one connect span, got %d", dialSpans)
+	}
+}
@@ -15,6 +15,7 @@ type Body map[string]interface{}
 type Command struct {
 	conn streams.Connection
 
-	Command string `cbor:"16,keyasint"`
-	Body    Body   `cbor:"64,keyasint"`
+	Command string            `cbor:"16,keyasint"`
+	Trace   map[string]string `cbor:"32,keyasint,omitempty"`
+	Body    Body              `cbor:"64,keyasint"`
 }
@@ -4,11 +4,16 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
+	"github.com/taubyte/p2p/metrics"
 	"github.com/taubyte/p2p/streams"
 	"github.com/taubyte/p2p/streams/command"
 	ce "github.com/taubyte/p2p/streams/command/error"
 	cr "github.com/taubyte/p2p/streams/command/response"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type CommandHandler func(context.Context, streams.Connection, command.Body) (cr.Response, error)
@@ -44,7 +49,7 @@ func (r *Router) AddStatic(command string, handler CommandHandler, stream Stream
 	return nil
 }
 
-func (r *Router) handle(cmd *command.Command) (cr.Response, StreamHandler, error) {
+func (r *Router) handle(ctx context.Context, cmd *command.Command) (cr.Response, StreamHandler, error) {
 	if cmd == nil {
 		return nil, nil, errors.New("empty command")
 	}
@@ -55,35 +60,79 @@ func (r *Router) handle(cmd *command.Command) (cr.Response, StreamHandler, error
 	}
 
 	if _handlers, ok := r.staticRoutes[cmd.Command]; ok {
-		ret, err := _handlers.std(r.svr.Context(), conn, cmd.Body)
+		ret, err := _handlers.std(ctx, conn, cmd.Body)
 		return ret, _handlers.stream, err
 	}
 
 	return nil, nil, errors.New("command `" + cmd.Command + "` does not exist.")
 }
 
+// unknownCommand names commands without a route in metrics and traces, so
+// peers can not make up labels.
+const unknownCommand = "unknown"
+
+const tracerName = "github.com/taubyte/p2p/streams/command/router"
+
+// routeName returns the name of the route of c.
+func (r *Router) routeName(c *command.Command) string {
+	if c != nil {
+		if _, ok := r.staticRoutes[c.Command]; ok {
+			return c.Command
+		}
+	}
+
+	return unknownCommand
+}
+
+// measure reports the command c handled since start.
+func (r *Router) measure(c *command.Command, start time.Time, err error) {
+	r.svr.Metrics().Command(r.routeName(c), metrics.Inbound, time.Since(start), err)
+}
+
+// Handle serves the command of s. Handlers get a context carrying the trace
+// context of the command, under a span covering the command and its stream.
 func (r *Router) Handle(s streams.Stream) {
 	defer s.Close()
 
+	start := time.Now()
 	c, err := command.Decode(s.Conn(), s)
 	if err != nil {
+		r.measure(nil, start, err)
 		ce.Encode(s, err)
 		return
 	}
 
-	creturn, upgrade, err := r.handle(c)
+	ctx, span := r.svr.TracerProvider().Tracer(tracerName).Start(
+		c.ExtractTrace(r.svr.Context()),
+		"handle "+r.routeName(c),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("p2p.command", r.routeName(c)),
+			attribute.String("p2p.protocol", string(s.Protocol())),
+			attribute.String("p2p.peer", s.Conn().RemotePeer().String()),
+		),
+	)
+	defer span.End()
+
+	creturn, upgrade, err := r.handle(ctx, c)
 	if err != nil {
+		r.measure(c, start, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		ce.Encode(s, err)
 		return
 	}
 
 	err = creturn.Encode(s)
+	r.measure(c, start, err)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		ce.Encode(s, err)
 		return
 	}
 
 	if upgrade != nil {
-		upgrade(r.svr.Context(), s)
+		upgrade(ctx, s)
 	}
 }
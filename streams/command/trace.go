@@ -0,0 +1,28 @@
+package command
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// propagator carries trace contexts in commands, in the W3C format.
+var propagator = propagation.TraceContext{}
+
+// InjectTrace sets the trace context of ctx on c, if any.
+func (c *Command) InjectTrace(ctx context.Context) {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) > 0 {
+		c.Trace = carrier
+	}
+}
+
+// ExtractTrace returns ctx with the trace context of c, if any.
+func (c *Command) ExtractTrace(ctx context.Context) context.Context {
+	if len(c.Trace) == 0 {
+		return ctx
+	}
+
+	return propagator.Extract(ctx, propagation.MapCarrier(c.Trace))
+}
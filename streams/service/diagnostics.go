@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/taubyte/p2p/peer"
+	"github.com/taubyte/p2p/streams"
+	"github.com/taubyte/p2p/streams/command"
+	cr "github.com/taubyte/p2p/streams/command/response"
+)
+
+// DiagnosticsCommand is the command defined by EnableDiagnostics.
+const DiagnosticsCommand = "diagnostics"
+
+// EnableDiagnostics defines DiagnosticsCommand, answering with the JSON
+// encoded diagnostics of the node. Use DecodeDiagnostics on the response.
+func (cs *CommandService) EnableDiagnostics() error {
+	return cs.Define(DiagnosticsCommand, func(context.Context, streams.Connection, command.Body) (cr.Response, error) {
+		data, err := json.Marshal(cs.peer.Diagnostics())
+		if err != nil {
+			return nil, fmt.Errorf("encoding diagnostics failed with: %w", err)
+		}
+
+		return cr.Response{"diagnostics": data}, nil
+	})
+}
+
+// DecodeDiagnostics returns the diagnostics in a response to
+// DiagnosticsCommand.
+func DecodeDiagnostics(resp cr.Response) (peer.Diagnostics, error) {
+	var d peer.Diagnostics
+
+	v, err := resp.Get("diagnostics")
+	if err != nil {
+		return d, err
+	}
+
+	data, ok := v.([]byte)
+	if !ok {
+		return d, fmt.Errorf("diagnostics is a %T, expected bytes", v)
+	}
+
+	if err = json.Unmarshal(data, &d); err != nil {
+		return d, fmt.Errorf("decoding diagnostics failed with: %w", err)
+	}
+
+	return d, nil
+}
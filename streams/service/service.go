@@ -40,6 +40,12 @@ func (cs *CommandService) Stop() {
 	cs.stream.Stop()
 }
 
+// SetLimits sets the limits of the inbound streams of the service. Without
+// them, only the limits of the node apply.
+func (cs *CommandService) SetLimits(limits peer.ProtocolLimits) error {
+	return cs.stream.SetLimits(limits)
+}
+
 func (cs *CommandService) Router() *(router.Router) {
 	return cs.router
 }
@@ -10,8 +10,11 @@ import (
 
 	peer "github.com/taubyte/p2p/peer"
 
+	"github.com/taubyte/p2p/streams/client"
 	"github.com/taubyte/p2p/streams/command"
 	cr "github.com/taubyte/p2p/streams/command/response"
+
+	peercore "github.com/libp2p/go-libp2p/core/peer"
 )
 
 func TestNewService(t *testing.T) {
@@ -36,7 +39,75 @@ func TestNewService(t *testing.T) {
 	if err != nil {
 		t.Errorf("Service creation returned error `%s`", err.Error())
 	}
+	if err = svr.SetLimits(peer.ProtocolLimits{StreamsInbound: 16, PeerStreamsInbound: 4}); err != nil {
+		t.Errorf("SetLimits returned error `%s`", err.Error())
+	}
+
 	svr.Define("hi", func(context.Context, streams.Connection, command.Body) (cr.Response, error) {
 		return cr.Response{"message": "HI"}, nil
 	})
 }
+
+func TestDiagnostics(t *testing.T) {
+	ctx, ctxC := context.WithCancel(context.Background())
+	defer ctxC()
+
+	p1, err := peer.NewNode(ctx, peer.WithListen("/ip4/127.0.0.1/tcp/0"), peer.WithBootstrap(peer.StandAlone()))
+	if err != nil {
+		t.Errorf("Peer creation returned error `%s`", err.Error())
+		return
+	}
+	defer p1.Close()
+
+	svr, err := New(p1, "hello", "/hello/1.0")
+	if err != nil {
+		t.Errorf("Service creation returned error `%s`", err.Error())
+		return
+	}
+	defer svr.Stop()
+
+	if err = svr.EnableDiagnostics(); err != nil {
+		t.Errorf("EnableDiagnostics returned error `%s`", err.Error())
+		return
+	}
+
+	p2, err := peer.NewNode(ctx, peer.WithListen("/ip4/127.0.0.1/tcp/0"), peer.WithBootstrap(peer.StandAlone()))
+	if err != nil {
+		t.Errorf("Peer creation returned error `%s`", err.Error())
+		return
+	}
+	defer p2.Close()
+
+	if err = p2.Peer().Connect(ctx, peercore.AddrInfo{ID: p1.ID(), Addrs: p1.Peer().Addrs()}); err != nil {
+		t.Errorf("Connect returned error `%s`", err.Error())
+		return
+	}
+
+	c, err := client.New(p2, "/hello/1.0")
+	if err != nil {
+		t.Errorf("Client creation returned error `%s`", err.Error())
+		return
+	}
+	defer c.Close()
+
+	res, err := c.SendTo(p1.ID(), DiagnosticsCommand, nil)
+	if err != nil {
+		t.Errorf("Sending diagnostics returned error `%s`", err.Error())
+		return
+	}
+
+	d, err := DecodeDiagnostics(res)
+	if err != nil {
+		t.Errorf("DecodeDiagnostics returned error `%s`", err.Error())
+		return
+	}
+
+	if d.ID != p1.ID() || len(d.Peers) != 1 || d.Peers[0].ID != p2.ID() {
+		t.Errorf("unexpected diagnostics %+v", d)
+	}
+
+	// the command is answered on an open stream of the service
+	if d.Streams["/hello/1.0"] == 0 {
+		t.Errorf("expected an open /hello/1.0 stream, got %v", d.Streams)
+	}
+}
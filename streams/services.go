@@ -4,9 +4,12 @@ import (
 	"context"
 	"io"
 
+	log "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p/core/network"
 	protocol "github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/taubyte/p2p/metrics"
 	"github.com/taubyte/p2p/peer"
+	"go.opentelemetry.io/otel/trace"
 
 	discoveryUtil "github.com/libp2p/go-libp2p/p2p/discovery/util"
 )
@@ -17,6 +20,21 @@ type Connection interface {
 	network.ConnMultiaddrs
 }
 
+// Security returns the security protocol negotiated for conn, like
+// "/tls/1.0.0" or "/noise". It is empty for transports securing connections
+// on their own, like QUIC, and for connections not reporting their state.
+func Security(conn Connection) protocol.ID {
+	if c, ok := conn.(interface {
+		ConnState() network.ConnectionState
+	}); ok {
+		return c.ConnState().Security
+	}
+
+	return ""
+}
+
+var logger = log.Logger("p2p.streams")
+
 type Stream network.Stream
 type StreamHandler func(Stream)
 
@@ -27,6 +45,7 @@ type StreamManger struct {
 	name       string
 	path       string
 	handler    StreamHandler
+	limits     *peer.ProtocolLimits
 }
 
 func New(peer peer.Node, name string, path string) *StreamManger {
@@ -44,9 +63,29 @@ func New(peer peer.Node, name string, path string) *StreamManger {
 	return &s
 }
 
+// SetLimits sets the limits of the inbound streams of the protocol, which are
+// only bounded by the limits of the node otherwise. Limits set before Start
+// are applied when it registers the protocol.
+func (s *StreamManger) SetLimits(limits peer.ProtocolLimits) error {
+	s.limits = &limits
+	if s.handler == nil {
+		return nil
+	}
+
+	return s.peer.SetProtocolLimits(protocol.ID(s.path), limits)
+}
+
+// Start registers the protocol with handler. Streams are still handled if its
+// limits can not be set, they are then not enforced.
 func (s *StreamManger) Start(handler StreamHandler) {
 	s.handler = handler
-	s.peer.Peer().SetStreamHandler(protocol.ID(s.path), func(ns network.Stream) {
+	if s.limits != nil {
+		if err := s.peer.SetProtocolLimits(protocol.ID(s.path), *s.limits); err != nil {
+			logger.Errorf("setting limits of `%s` failed with: %s, they are not enforced", s.path, err)
+		}
+	}
+
+	s.peer.SetStreamHandler(protocol.ID(s.path), func(ns network.Stream) {
 		s.handler(Stream(ns))
 	})
 }
@@ -58,3 +97,13 @@ func (s *StreamManger) Stop() {
 func (s *StreamManger) Context() context.Context {
 	return s.ctx
 }
+
+// Metrics returns the metrics of the node of s.
+func (s *StreamManger) Metrics() metrics.Metrics {
+	return s.peer.Metrics()
+}
+
+// TracerProvider returns the tracer provider of the node of s.
+func (s *StreamManger) TracerProvider() trace.TracerProvider {
+	return s.peer.TracerProvider()
+}